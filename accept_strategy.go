@@ -0,0 +1,91 @@
+package adaptivepool
+
+import (
+	"math"
+	"sync"
+)
+
+// AcceptStrategy decides whether an item should be retained by an
+// [AdaptivePool], separately from how it's measured and created. It can be set
+// with [AdaptivePool.SetAcceptStrategy] to replace [PoolItemProvider.Accept],
+// which allows combining the sizing of a provider with a different policy. Any
+// PoolItemProvider is also an AcceptStrategy.
+type AcceptStrategy interface {
+	// Accept has the same semantics as [PoolItemProvider.Accept].
+	Accept(mean, stdDev, itemSize float64) bool
+}
+
+// NormalBand is an [AcceptStrategy] that accepts items whose size is in the
+// inclusive range `mean - LowerThreshold * stdDev` to `mean + UpperThreshold *
+// stdDev`, or any item if `stdDev` is NaN. Threshold is used instead of each
+// of them that is not positive. This is the policy of the providers in this
+// package that assume a Normal Distribution, like [NormalSlice].
+type NormalBand struct {
+	Threshold                      float64
+	LowerThreshold, UpperThreshold float64
+}
+
+// Accept implements AcceptStrategy.
+func (s NormalBand) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAcceptBand(mean, stdDev, bandThreshold(s.LowerThreshold,
+		s.Threshold), bandThreshold(s.UpperThreshold, s.Threshold), itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if any of the
+// thresholds is negative or NaN.
+func (s NormalBand) Validate() error {
+	return validateThresholds("NormalBand", s.Threshold, s.LowerThreshold,
+		s.UpperThreshold)
+}
+
+// PercentileBound is an [AcceptStrategy] that accepts items whose size is at
+// most the estimated percentile of the sizes it's been asked about, or any item
+// if there is no estimation yet. The `mean` and `stdDev` arguments are ignored.
+// Like [PercentileSlice], it makes no assumption about the distribution of the
+// sizes. Sizes are only estimated when Accept is called, so PutForce does not
+// contribute to them. It holds the state of the estimation, so it must be
+// created with [NewPercentileBound] and it should not be shared by multiple
+// [AdaptivePool]s. It is safe for concurrent use.
+type PercentileBound struct {
+	mu  sync.Mutex
+	est *Percentile
+}
+
+// NewPercentileBound returns a new PercentileBound for the given percentile,
+// which must be in the range (0, 1).
+func NewPercentileBound(p float64) *PercentileBound {
+	return &PercentileBound{
+		est: NewPercentile(p),
+	}
+}
+
+// Percentile returns the current estimation of the percentile, or NaN if
+// Accept was never called.
+func (s *PercentileBound) Percentile() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.est.Value()
+}
+
+// Accept adds `itemSize` to the estimation, and then accepts the item if it's
+// at most the estimated percentile.
+func (s *PercentileBound) Accept(mean, stdDev, itemSize float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.est.Push(itemSize)
+	v := s.est.Value()
+	return math.IsNaN(v) || itemSize <= v
+}
+
+// AcceptAll is an [AcceptStrategy] that accepts every item.
+type AcceptAll struct{}
+
+// Accept implements AcceptStrategy.
+func (AcceptAll) Accept(mean, stdDev, itemSize float64) bool { return true }
+
+// AcceptNone is an [AcceptStrategy] that rejects every item, so that the pool
+// only creates items with the learned size.
+type AcceptNone struct{}
+
+// Accept implements AcceptStrategy.
+func (AcceptNone) Accept(mean, stdDev, itemSize float64) bool { return false }
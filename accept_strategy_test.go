@@ -0,0 +1,115 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAdaptivePoolSetAcceptStrategy(t *testing.T) {
+	t.Parallel()
+	sizes := []int{10, 10, 10, 11, 9, 1000, 0, 10, 500, 10}
+
+	ap, sp := newStackAdaptivePool[[]byte](NormalSlice[byte]{Threshold: 1}, 0)
+	ap.SetAcceptStrategy(AcceptAll{})
+	var onDrop int
+	ap.SetOnDrop(func(itemSize, mean, stdDev float64) { onDrop++ })
+	for _, size := range sizes {
+		ap.Put(make([]byte, size, max(size, 1)))
+	}
+	equal(t, len(sizes), sp.Len(), "AcceptAll should retain every item")
+	m := ap.Metrics()
+	zero(t, m.Drops, "Drops")
+	zero(t, onDrop, "OnDrop calls")
+	equal(t, float64(len(sizes)), m.N, "sizes should still be measured")
+	equal(t, NormalSlice[byte]{Threshold: 1}.CreateSize(m.Mean, m.StdDev),
+		ap.CreateSize(), "the provider should still create items")
+
+	none, sp := newStackAdaptivePool[[]byte](NormalSlice[byte]{}, 0)
+	none.SetAcceptStrategy(AcceptNone{})
+	for _, size := range sizes {
+		none.Put(make([]byte, size, max(size, 1)))
+	}
+	zero(t, sp.Len(), "AcceptNone should retain nothing")
+	equal(t, uint64(len(sizes)), none.Metrics().Drops, "Drops")
+
+	// PutForce bypasses the strategy, and nil restores the provider's Accept
+	none.PutForce(make([]byte, 10))
+	equal(t, 1, sp.Len(), "PutForce should retain")
+	none.SetAcceptStrategy(nil)
+	none.Put(make([]byte, 1000))
+	equal(t, 1, sp.Len(), "provider Accept should reject the outlier")
+
+	// the strategy also takes precedence over SizeAccepter
+	var sizeofCalls, sizeAndAcceptCalls int
+	sa, sp := newStackAdaptivePool[*bytes.Buffer](sizeAcceptBuffer{
+		sizeofCalls:        &sizeofCalls,
+		sizeAndAcceptCalls: &sizeAndAcceptCalls,
+	}, 0)
+	sa.SetAcceptStrategy(AcceptAll{})
+	sa.Put(bytes.NewBuffer(make([]byte, 10)))
+	sa.Put(bytes.NewBuffer(make([]byte, 1000)))
+	equal(t, 2, sp.Len(), "retained items")
+	equal(t, 2, sizeofCalls, "Sizeof should be used")
+	zero(t, sizeAndAcceptCalls, "SizeAndAccept should not be used")
+}
+
+func TestNormalBand(t *testing.T) {
+	t.Parallel()
+
+	// NormalBand should match the policy of the Normal providers
+	bands := []NormalBand{
+		{Threshold: 1},
+		{Threshold: 2, LowerThreshold: 0.5},
+		{Threshold: 1, UpperThreshold: 3},
+	}
+	for i, band := range bands {
+		provider := NormalSlice[byte]{
+			Threshold:      band.Threshold,
+			LowerThreshold: band.LowerThreshold,
+			UpperThreshold: band.UpperThreshold,
+		}
+		for _, stdDev := range []float64{math.NaN(), 0, 2} {
+			for size := 0.0; size <= 20; size++ {
+				equal(t, provider.Accept(10, stdDev, size),
+					band.Accept(10, stdDev, size),
+					"[#%d] stdDev=%v, size=%v", i, stdDev, size)
+			}
+		}
+	}
+
+	err := NormalBand{Threshold: 1}.Validate()
+	zero(t, err, "valid band")
+	err = NormalBand{UpperThreshold: -1}.Validate()
+	equal(t, true, errors.Is(err, ErrInvalidThreshold), "invalid band: %v",
+		err)
+}
+
+func TestPercentileBound(t *testing.T) {
+	t.Parallel()
+
+	s := NewPercentileBound(0.5)
+	equal(t, true, math.IsNaN(s.Percentile()), "no estimation")
+	equal(t, true, s.Accept(0, 0, 1000), "first size is the percentile")
+	for range 100 {
+		for _, size := range []float64{10, 20, 30} {
+			s.Accept(0, 0, size)
+		}
+	}
+	p := s.Percentile()
+	equal(t, true, p >= 10 && p <= 30, "median estimation: %v", p)
+	equal(t, true, s.Accept(0, 0, 10), "size below the median")
+	equal(t, false, s.Accept(0, 0, 1000), "size above the median")
+
+	ap, sp := newStackAdaptivePool[[]byte](NormalSlice[byte]{}, 0)
+	ap.SetAcceptStrategy(NewPercentileBound(0.9))
+	for i := range 100 {
+		ap.Put(make([]byte, 10+i%10))
+	}
+	retained := sp.Len()
+	equal(t, true, retained > 80, "most items should be retained: %v",
+		retained)
+	ap.Put(make([]byte, 1e4))
+	equal(t, retained, sp.Len(), "the outlier should be dropped")
+}
@@ -5,9 +5,16 @@ package adaptivepool
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // PoolItemProvider handles both item type-specific operations as well as the
@@ -21,8 +28,10 @@ type PoolItemProvider[T any] interface {
 	// allows handling items with a virtual size, like a slice. For instance, a
 	// slice with zero cap should return -1 (or any negative value) so that it's
 	// not unnecessarily put back into the pool, while it is totally fine to
-	// return 0 for a slice with cap greater than zero. Implementations should
-	// not hold references to the item.
+	// return 0 for a slice with cap greater than zero. Likewise, a nil pointer
+	// should return a negative number, so that Put of a nil pointer leaves the
+	// stats unchanged and retains nothing. Implementations should not hold
+	// references to the item.
 	Sizeof(T) float64
 	// Create returns a new item. It has a set of basic stats about the
 	// AdaptivePool usage that allows efficient pre-allocation in many common
@@ -34,156 +43,1400 @@ type PoolItemProvider[T any] interface {
 	Accept(mean, stdDev, itemSize float64) bool
 }
 
+// CreateSizer is an optional interface that a [PoolItemProvider] can implement
+// to report the size of the items it would create, without creating them.
+type CreateSizer interface {
+	// CreateSize returns the size of the item that Create would return when
+	// called with the same arguments.
+	CreateSize(mean, stdDev float64) float64
+}
+
+// SizeAccepter is an optional interface that a [PoolItemProvider] can
+// implement to measure an item and decide whether to accept it with a single
+// method call, which is useful when measuring and deciding share expensive
+// work, like walking a large structure. The returned size has the same
+// semantics as [PoolItemProvider.Sizeof]. The arguments `mean` and `stdDev` are
+// the statistics before the size of `item` is pushed to them, while
+// [PoolItemProvider.Accept] receives the statistics after that, which already
+// include the item. Both receive a NaN `stdDev` for the first item of a pool,
+// and make the same decision for sizes clearly inside or outside of the
+// accepted range, but they may differ for sizes near its bounds. For example,
+// after putting items of sizes 10, 10 and 10, an item of size 11 is rejected
+// by [NormalSlice] with a Threshold of 2 if it uses the statistics before the
+// push, since `stdDev` is zero, but accepted with the ones after it. For this
+// reason, the providers in this package don't implement SizeAccepter, so that
+// their decisions don't change.
+type SizeAccepter[T any] interface {
+	SizeAndAccept(mean, stdDev float64, item T) (size float64, accept bool)
+}
+
+// ResettingProvider is an optional interface that a [PoolItemProvider] can
+// implement to prepare items for reuse, so that callers don't need to do it
+// after each Get.
+type ResettingProvider[T any] interface {
+	// Reset is called by [AdaptivePool.Put] on each item that is retained,
+	// after measuring it and before putting it into the pool.
+	Reset(item T)
+}
+
+// Validator is an optional interface that a [PoolItemProvider] can implement
+// to check its configuration, which is used by [NewChecked]. It is
+// implemented by all the providers in this package that have a Threshold.
+type Validator interface {
+	Validate() error
+}
+
+// ErrInvalidThreshold is returned by the Validate methods of the
+// PoolItemProviders in this package when a threshold is negative or NaN, which
+// would make them reject every item.
+var ErrInvalidThreshold = errors.New("invalid threshold")
+
+func validateThresholds(typ string, thresholds ...float64) error {
+	for _, t := range thresholds {
+		if !(t >= 0) {
+			return fmt.Errorf("%s.Validate: %w: %v", typ, ErrInvalidThreshold,
+				t)
+		}
+	}
+	return nil
+}
+
 // NormalSlice is a generic [PoolItemProvider] for slice items, operating under
-// the assumption that their `len` follow a Normal Distribution.
+// the assumption that their `len` follow a Normal Distribution. If CapBased is
+// set, then their `cap` is used instead, which is useful when items are
+// resliced to zero length before Put.
 type NormalSlice[T any] struct {
 	MinCap    int     // Minimum capacity of a newly created slice
+	MaxCap    int     // Maximum capacity of a newly created slice, if positive
 	Threshold float64 // Threshold must be non-negative.
+	CapBased  bool    // Measure slices by their capacity instead of length
+
+	// LowerThreshold and UpperThreshold, if positive, replace Threshold in
+	// Accept for items smaller and greater than the mean, respectively.
+	LowerThreshold, UpperThreshold float64
+
+	// AcceptLarger makes Accept keep items of any size above the lower
+	// bound, for pools that should never shrink because growing items is
+	// much more expensive than retaining the occasional large one.
+	AcceptLarger bool
 }
 
-// Sizeof returns the length of the slice.
+// Sizeof returns the length of the slice, or its capacity if CapBased is set.
 func (p NormalSlice[T]) Sizeof(v []T) float64 {
 	if cap(v) == 0 {
 		return -1
 	}
+	if p.CapBased {
+		return float64(cap(v))
+	}
 	return float64(len(v))
 }
 
 // Create returns a new slice with length zero and cap `mean + Threshold *
 // stdDev`, or `mean` if `stdDev` is `NaN`.
 func (p NormalSlice[T]) Create(mean, stdDev float64) []T {
-	size := int(normalCreateSize(mean, stdDev, p.Threshold))
-	size = max(size, p.MinCap)
-	return make([]T, 0, size)
+	return make([]T, 0, int(p.CreateSize(mean, stdDev)))
 }
 
-// Accept will accept a new item if its length is in the inclusive range `mean ±
-// Threshold * stdDev`, or if `stdDev` is `NaN`.
+// CreateSize returns the capacity of the slices returned by Create, which is
+// never less than MinCap, nor greater than MaxCap if it's positive, which
+// takes precedence.
+func (p NormalSlice[T]) CreateSize(mean, stdDev float64) float64 {
+	size := max(int(normalCreateSize(mean, stdDev, p.Threshold)), p.MinCap)
+	if p.MaxCap > 0 {
+		size = min(size, p.MaxCap)
+	}
+	return float64(size)
+}
+
+// Accept will accept a new item if its length is in the inclusive range `mean -
+// LowerThreshold * stdDev` to `mean + UpperThreshold * stdDev`, or if `stdDev`
+// is `NaN`. Threshold is used instead of each of them that is not positive.
+// If AcceptLarger is set, then there is no upper bound.
 func (p NormalSlice[T]) Accept(mean, stdDev, itemSize float64) bool {
-	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+	return normalAcceptBand(mean, stdDev, bandThreshold(p.LowerThreshold,
+		p.Threshold), upperThreshold(p.UpperThreshold, p.Threshold,
+		p.AcceptLarger), itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if any of the
+// thresholds is negative or NaN.
+func (p NormalSlice[T]) Validate() error {
+	return validateThresholds("NormalSlice", p.Threshold, p.LowerThreshold,
+		p.UpperThreshold)
+}
+
+// NormalSlicePtr is like [NormalSlice], but for pointers to slices. Putting a
+// slice in a [sync.Pool] requires allocating a copy of its header in the heap
+// to store it as an `any`, which for small slices can be a significant fraction
+// of the cost of allocating a new one. Pointers are stored without allocating,
+// so this provider should be preferred for pools of small slices. Callers
+// should reslice the pointed slice to zero length after Get, and Put it back
+// with the length that was used. Example:
+//
+//	pool := New(NormalSlicePtr[float64]{
+//		NormalSlice: NormalSlice[float64]{Threshold: 2},
+//	}, 500)
+//	rec := pool.Get()
+//	*rec = append((*rec)[:0], 1, 2, 3)
+//	pool.Put(rec)
+type NormalSlicePtr[T any] struct {
+	NormalSlice[T]
+}
+
+// Sizeof returns the length of the pointed slice, or -1 for a nil pointer.
+func (p NormalSlicePtr[T]) Sizeof(v *[]T) float64 {
+	if v == nil {
+		return -1
+	}
+	return p.NormalSlice.Sizeof(*v)
+}
+
+// Create returns a pointer to a new slice created as with
+// [NormalSlice.Create].
+func (p NormalSlicePtr[T]) Create(mean, stdDev float64) *[]T {
+	v := p.NormalSlice.Create(mean, stdDev)
+	return &v
 }
 
 // NormalBytesBuffer is a [PoolItemProvider] for [*bytes.Buffer] items,
 // operating under the assumption that their `Len` follow a Normal Distribution.
+// If CapBased is set, then their `Cap` is used instead, which is useful when
+// items are Reset before Put. It implements [ResettingProvider], so Put resets
+// the buffers it retains, and Get never returns a buffer with data from a
+// previous use. Buffers that Put drops are left untouched.
 type NormalBytesBuffer struct {
 	MinCap    int     // Minimum capacity of a newly created *bytes.Buffer
+	MaxCap    int     // Maximum capacity of a new *bytes.Buffer, if positive
 	Threshold float64 // Threshold must be non-negative.
+	CapBased  bool    // Measure buffers by their capacity instead of length
+
+	// LowerThreshold and UpperThreshold, if positive, replace Threshold in
+	// Accept for items smaller and greater than the mean, respectively.
+	LowerThreshold, UpperThreshold float64
+
+	// AcceptLarger makes Accept keep items of any size above the lower
+	// bound, for pools that should never shrink because growing items is
+	// much more expensive than retaining the occasional large one.
+	AcceptLarger bool
 }
 
-// Sizeof returns the length of the buffer.
+// Sizeof returns the length of the buffer, or its capacity if CapBased is set.
 func (p NormalBytesBuffer) Sizeof(v *bytes.Buffer) float64 {
 	if v == nil || v.Cap() == 0 {
 		return -1
 	}
+	if p.CapBased {
+		return float64(v.Cap())
+	}
 	return float64(v.Len())
 }
 
 // Create returns a new buffer with `Len` zero and `Cap` `mean + Threshold *
 // stdDev`, or `mean` if `stdDev` is `NaN`.
 func (p NormalBytesBuffer) Create(mean, stdDev float64) *bytes.Buffer {
-	size := int(normalCreateSize(mean, stdDev, p.Threshold))
-	size = max(size, p.MinCap)
-	return bytes.NewBuffer(make([]byte, 0, size))
+	return bytes.NewBuffer(make([]byte, 0, int(p.CreateSize(mean, stdDev))))
+}
+
+// CreateSize returns the `Cap` of the buffers returned by Create, which is
+// never less than MinCap, nor greater than MaxCap if it's positive, which
+// takes precedence.
+func (p NormalBytesBuffer) CreateSize(mean, stdDev float64) float64 {
+	size := max(int(normalCreateSize(mean, stdDev, p.Threshold)), p.MinCap)
+	if p.MaxCap > 0 {
+		size = min(size, p.MaxCap)
+	}
+	return float64(size)
 }
 
-// Accept will accept a new item if its `Len` is in the inclusive range `mean ±
-// Threshold * stdDev`, or if `stdDev` is `NaN`.
+// Accept will accept a new item if its size is in the inclusive range `mean -
+// LowerThreshold * stdDev` to `mean + UpperThreshold * stdDev`, or if `stdDev`
+// is `NaN`. Threshold is used instead of each of them that is not positive.
+// If AcceptLarger is set, then there is no upper bound.
 func (p NormalBytesBuffer) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAcceptBand(mean, stdDev, bandThreshold(p.LowerThreshold,
+		p.Threshold), upperThreshold(p.UpperThreshold, p.Threshold,
+		p.AcceptLarger), itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if any of the
+// thresholds is negative or NaN.
+func (p NormalBytesBuffer) Validate() error {
+	return validateThresholds("NormalBytesBuffer", p.Threshold,
+		p.LowerThreshold, p.UpperThreshold)
+}
+
+// Reset empties the buffer, keeping its capacity. It implements
+// [ResettingProvider].
+func (p NormalBytesBuffer) Reset(v *bytes.Buffer) {
+	v.Reset()
+}
+
+// NormalStringsBuilder is a [PoolItemProvider] for [*strings.Builder] items,
+// operating under the assumption that their `Len` follow a Normal
+// Distribution. A strings.Builder can't be truncated, and its Reset method
+// drops its buffer, so callers should Put them after use without calling
+// Reset, so that their `Len` is measured. Instead, retained builders are reset
+// by the AdaptivePool with [NormalStringsBuilder.Reset] before being put into
+// the pool.
+type NormalStringsBuilder struct {
+	MinCap    int     // Minimum capacity of a newly created *strings.Builder
+	Threshold float64 // Threshold must be non-negative.
+}
+
+// Sizeof returns the length of the builder.
+func (p NormalStringsBuilder) Sizeof(v *strings.Builder) float64 {
+	if v == nil || v.Cap() == 0 {
+		return -1
+	}
+	return float64(v.Len())
+}
+
+// Create returns a new builder grown to `Cap` `mean + Threshold * stdDev`, or
+// `mean` if `stdDev` is `NaN`.
+func (p NormalStringsBuilder) Create(mean, stdDev float64) *strings.Builder {
+	b := new(strings.Builder)
+	b.Grow(int(p.CreateSize(mean, stdDev)))
+	return b
+}
+
+// CreateSize returns the `Cap` of the builders returned by Create, which is
+// never less than MinCap.
+func (p NormalStringsBuilder) CreateSize(mean, stdDev float64) float64 {
+	size := int(normalCreateSize(mean, stdDev, p.Threshold))
+	return float64(max(size, p.MinCap, 0))
+}
+
+// Accept will accept a new item if its `Len` is in the inclusive range
+// `mean ± Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p NormalStringsBuilder) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+}
+
+// Reset empties the builder, and grows it back to its previous capacity. It
+// implements [ResettingProvider]. The strings returned by the String method of
+// a strings.Builder share its buffer, so it can't be reused, and instead a new
+// one is allocated.
+func (p NormalStringsBuilder) Reset(v *strings.Builder) {
+	c := v.Cap()
+	v.Reset()
+	v.Grow(c)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p NormalStringsBuilder) Validate() error {
+	return validateThresholds("NormalStringsBuilder", p.Threshold)
+}
+
+// NormalMap is a generic [PoolItemProvider] for map items, operating under the
+// assumption that their `len` follow a Normal Distribution. Maps cannot be
+// truncated, so items are retained with their entries unless Clear is set, and
+// callers should `clear` the maps they Get otherwise. Note that clearing a map
+// does not shrink its underlying storage, which is what makes them worth
+// reusing.
+type NormalMap[K comparable, V any] struct {
+	MinCap    int     // Minimum capacity hint of a newly created map
+	Threshold float64 // Threshold must be non-negative.
+	Clear     bool    // Clear maps in Sizeof, after measuring them
+}
+
+// Sizeof returns the length of the map, or -1 for a nil map. If Clear is set,
+// then the map is cleared after measuring it.
+func (p NormalMap[K, V]) Sizeof(v map[K]V) float64 {
+	if v == nil {
+		return -1
+	}
+	size := float64(len(v))
+	if p.Clear {
+		clear(v)
+	}
+	return size
+}
+
+// Create returns a new empty map with capacity hint `mean + Threshold *
+// stdDev`, or `mean` if `stdDev` is `NaN`.
+func (p NormalMap[K, V]) Create(mean, stdDev float64) map[K]V {
+	return make(map[K]V, int(p.CreateSize(mean, stdDev)))
+}
+
+// CreateSize returns the capacity hint of the maps returned by Create, which
+// is never less than MinCap.
+func (p NormalMap[K, V]) CreateSize(mean, stdDev float64) float64 {
+	size := int(normalCreateSize(mean, stdDev, p.Threshold))
+	return float64(max(size, p.MinCap))
+}
+
+// Accept will accept a new item if its length is in the inclusive range
+// `mean ± Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p NormalMap[K, V]) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p NormalMap[K, V]) Validate() error {
+	return validateThresholds("NormalMap", p.Threshold)
+}
+
+// NormalChan is a generic [PoolItemProvider] for buffered channel items,
+// operating under the assumption that their `cap` follow a Normal
+// Distribution. Channels can't be resized, so Accept is the main control over
+// which capacities are reused, and callers may create channels with a greater
+// capacity when needed, which makes the created ones grow. Callers must drain
+// the channels before Put, otherwise they are not retained.
+type NormalChan[T any] struct {
+	MinCap    int     // Minimum capacity of a newly created channel
+	Threshold float64 // Threshold must be non-negative.
+}
+
+// Sizeof returns the capacity of the channel, or -1 for a nil channel or a
+// channel that was not drained.
+func (p NormalChan[T]) Sizeof(v chan T) float64 {
+	if v == nil || len(v) > 0 {
+		return -1
+	}
+	return float64(cap(v))
+}
+
+// Create returns a new channel with capacity `mean + Threshold * stdDev`, or
+// `mean` if `stdDev` is `NaN`.
+func (p NormalChan[T]) Create(mean, stdDev float64) chan T {
+	return make(chan T, int(p.CreateSize(mean, stdDev)))
+}
+
+// CreateSize returns the capacity of the channels returned by Create, which is
+// never less than MinCap.
+func (p NormalChan[T]) CreateSize(mean, stdDev float64) float64 {
+	size := int(normalCreateSize(mean, stdDev, p.Threshold))
+	return float64(max(size, p.MinCap, 0))
+}
+
+// Accept will accept a new item if its capacity is in the inclusive range
+// `mean ± Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p NormalChan[T]) Accept(mean, stdDev, itemSize float64) bool {
 	return normalAccept(mean, stdDev, p.Threshold, itemSize)
 }
 
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p NormalChan[T]) Validate() error {
+	return validateThresholds("NormalChan", p.Threshold)
+}
+
 // AdaptivePool is a [sync.Pool] that uses a [PoolItemProvider] to efficiently
 // create and reuse new pool items. Statistics are updated each time the `Put`
 // method is called for an item.
 type AdaptivePool[T any] struct {
-	pool     pool
-	provider PoolItemProvider[T]
+	// pool is replaced by Reset, and newPool creates a new one
+	pool         atomic.Pointer[pool]
+	newPool      func() pool
+	provider     PoolItemProvider[T]
+	sizeAccepter SizeAccepter[T]      // nil if not implemented by provider
+	createSizer  CreateSizer          // nil if not implemented by provider
+	resetter     ResettingProvider[T] // nil if not implemented by provider
+	strategy     AcceptStrategy       // nil to use the provider
 
 	// reading is lock-free, and actually uses 32bit floating points to store
 	// mean and stdDev in a single 64bit atomic value
 	rStats atomic.Uint64
+	// rStatsGen is incremented each time rStats is updated by Put
+	rStatsGen atomic.Uint64
 
 	statsMu sync.RWMutex
-	stats   Stats
+	stats   StatsProvider // *Stats unless set with NewWithStats
+	status  statusTracker
+
+	// waiters is the number of goroutines blocked in GetWait. When it's zero,
+	// Put doesn't need to touch waitMu
+	waiters   atomic.Int32
+	waitMu    sync.Mutex
+	waitCh    chan struct{}
+	waitAlloc bool
+
+	prePut func(size float64) bool
+
+	// retained approximates the number of items in pool when maxItems is set
+	maxItems int64
+	retained atomic.Int64
+
+	precise  bool
+	onCreate func(mean, stdDev, size float64)
+	onDrop   func(itemSize, mean, stdDev float64)
+
+	// spike detection. recent is guarded by statsMu, and spikeMean has the
+	// bits of the float64 mean used to create items during a spike, or zero
+	spikeAlpha  float64
+	spikeFactor float64
+	recent      float64
+	spikeMean   atomic.Uint64
+
+	// hist is guarded by statsMu
+	hist      *Histogram
+	histQuant float64
+
+	clock    clock
+	memStats memStatsReader
+
+	gets, puts, drops, misses atomic.Uint64
+
+	pressure atomic.Bool // high heap usage detected
+	gcStop   chan struct{}
+	gcDone   chan struct{}
+
+	decayStop chan struct{}
+	decayDone chan struct{}
+
+	closed atomic.Bool
 }
 
 // New creates an AdaptivePool. See [Stats.SetMaxN] for a description of the
-// `maxN` argument.
+// `maxN` argument. It panics if `p` is nil. See also [NewChecked].
 func New[T any](p PoolItemProvider[T], maxN float64) *AdaptivePool[T] {
 	return new(AdaptivePool[T]).init(p, maxN)
 }
 
+// ErrNilProvider is returned by [NewChecked] when the PoolItemProvider is nil.
+var ErrNilProvider = errors.New("nil PoolItemProvider")
+
+// NewChecked is like [New], but it returns an error wrapping ErrNilProvider if
+// `p` is nil, instead of panicking, which allows handling configuration
+// mistakes gracefully. If `p` implements [Validator], then it also returns the
+// error from its Validate method, like one wrapping ErrInvalidThreshold.
+func NewChecked[T any](
+	p PoolItemProvider[T],
+	maxN float64,
+) (*AdaptivePool[T], error) {
+	if p == nil {
+		return nil, fmt.Errorf("NewChecked: %w", ErrNilProvider)
+	}
+	if v, ok := p.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("NewChecked: %w", err)
+		}
+	}
+	return New(p, maxN), nil
+}
+
+// NewSeeded is like [New], but the statistics of the AdaptivePool start with a
+// copy of `seed`, so that the first created items are already well-sized. The
+// value of `maxN` takes precedence over the one in `seed`. See
+// [NewStatsSeed].
+func NewSeeded[T any](
+	p PoolItemProvider[T],
+	maxN float64,
+	seed Stats,
+) *AdaptivePool[T] {
+	ap := New(p, maxN)
+	ap.stats = &seed
+	ap.stats.SetMaxN(maxN)
+	ap.storeRStats()
+	return ap
+}
+
+// NewBytePool is a shorthand to create an AdaptivePool of []byte items using a
+// [NormalSlice] with the given MinCap and Threshold. Example:
+//
+//	pool := NewBytePool(512, 2, 500)
+func NewBytePool(minCap int, thresh, maxN float64) *AdaptivePool[[]byte] {
+	return New[[]byte](NormalSlice[byte]{
+		MinCap:    minCap,
+		Threshold: thresh,
+	}, maxN)
+}
+
+// NewBytesBufferPool is a shorthand to create an AdaptivePool of
+// *bytes.Buffer items using a [NormalBytesBuffer] with the given MinCap and
+// Threshold. Example:
+//
+//	pool := NewBytesBufferPool(512, 2, 500)
+func NewBytesBufferPool(
+	minCap int,
+	thresh, maxN float64,
+) *AdaptivePool[*bytes.Buffer] {
+	return New[*bytes.Buffer](NormalBytesBuffer{
+		MinCap:    minCap,
+		Threshold: thresh,
+	}, maxN)
+}
+
+// StatsProvider computes the statistics of an [AdaptivePool]. It is
+// implemented by [*Stats], which is used by default, and it allows using custom
+// implementations with [NewWithStats], like an exponentially weighted
+// estimator. Implementations need not be safe for concurrent use.
+type StatsProvider interface {
+	Push(v float64)
+	Reset()
+	N() float64
+	MaxN() float64
+	SetMaxN(maxN float64)
+	Mean() float64
+	StdDev() float64
+}
+
+// NewWithStats is like [New], but the AdaptivePool uses `st` to compute its
+// statistics, which should not be used afterwards by the caller. Its MaxN is
+// kept. If it's not a [*Stats], then [AdaptivePool.Stats] returns a Stats that
+// only reproduces its N, MaxN, Mean and StdDev, and [AdaptivePool.LoadState]
+// is not supported.
+func NewWithStats[T any](
+	p PoolItemProvider[T],
+	st StatsProvider,
+) *AdaptivePool[T] {
+	ap := new(AdaptivePool[T])
+	ap.stats = st
+	return ap.init(p, st.MaxN())
+}
+
 func (p *AdaptivePool[T]) init(
 	pp PoolItemProvider[T],
 	maxN float64,
 ) *AdaptivePool[T] {
+	if pp == nil {
+		panic("adaptivepool: " + ErrNilProvider.Error())
+	}
 	p.provider = pp
-	p.stats.SetMaxN(maxN)
-	p.pool = &sync.Pool{
-		New: p.new,
+	p.sizeAccepter, _ = pp.(SizeAccepter[T])
+	p.createSizer, _ = pp.(CreateSizer)
+	p.resetter, _ = pp.(ResettingProvider[T])
+	p.clock = realClock{}
+	p.memStats = runtimeMemStats{}
+	if p.stats == nil {
+		p.stats = new(Stats)
 	}
+	p.stats.SetMaxN(maxN)
+	// the zero value of rStats decodes to a zero stdDev instead of NaN
+	p.storeRStats()
+	p.newPool = newSyncPool
+	p.setPool(p.newPool())
 	return p
 }
 
 // Stats returns a snapshot of the pool statistics.
 func (p *AdaptivePool[T]) Stats() Stats {
+	p.statsMu.RLock()
+	defer p.statsMu.RUnlock()
+	if st, ok := p.stats.(*Stats); ok {
+		return *st
+	}
+	st := NewStatsSeed(p.stats.N(), p.stats.Mean(), p.stats.StdDev())
+	st.SetMaxN(p.stats.MaxN())
+	return st
+}
+
+// MaxN returns the MaxN of the pool statistics. See [Stats.MaxN].
+func (p *AdaptivePool[T]) MaxN() float64 {
+	p.statsMu.RLock()
+	defer p.statsMu.RUnlock()
+	return p.stats.MaxN()
+}
+
+// SetMaxN changes the MaxN of the pool statistics, which allows tuning how
+// fast the pool adapts without recreating it. See [Stats.SetMaxN] for a
+// description of the `maxN` argument. It is safe for concurrent use.
+func (p *AdaptivePool[T]) SetMaxN(maxN float64) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	p.stats.SetMaxN(maxN)
+	p.storeRStats()
+}
+
+// Reset discards all the statistics and pooled items, as if the pool had just
+// been created, while keeping its configuration, like MaxN. This is useful when
+// the workload changes dramatically, instead of waiting for the statistics to
+// adapt. It is safe for concurrent use, although concurrent calls to Put may
+// have their items or sizes applied to either the old or the new state.
+func (p *AdaptivePool[T]) Reset() {
 	p.statsMu.Lock()
 	defer p.statsMu.Unlock()
-	return p.stats
+	if st, ok := p.stats.(*Stats); ok {
+		st.resetData()
+	} else {
+		maxN := p.stats.MaxN()
+		p.stats.Reset()
+		p.stats.SetMaxN(maxN)
+	}
+	p.status = statusTracker{}
+	p.recent = math.NaN()
+	p.spikeMean.Store(0)
+	if p.hist != nil {
+		p.hist.Reset()
+	}
+	p.storeRStats()
+	p.retained.Store(0)
+	p.setPool(p.newPool())
+}
+
+// DropCached discards all the pooled items, keeping the statistics, so that
+// the memory held by them can be freed after a burst, while the created items
+// are still well-sized. See also [AdaptivePool.Reset]. It is safe for
+// concurrent use, although concurrent calls to Put may have their items put
+// into either the old or the new pool.
+func (p *AdaptivePool[T]) DropCached() {
+	p.setPool(p.newPool())
+	p.retained.Store(0)
+}
+
+// stateMagic is the prefix of the data written by SaveState, followed by a
+// version byte and the binary encoding of the Stats.
+const (
+	stateMagic   = "APST"
+	stateVersion = 1
+)
+
+// SaveState writes the statistics of the pool to `w`, so that they can be later
+// restored with LoadState, for example to warm start the pool after a restart.
+// Pooled items are not saved.
+func (p *AdaptivePool[T]) SaveState(w io.Writer) error {
+	st := p.Stats()
+	b, err := st.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshal stats: %w", err)
+	}
+	b = append(append([]byte(stateMagic), stateVersion), b...)
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// LoadState replaces the statistics of the pool with the ones read from `r`,
+// which should have been written with SaveState. The current configuration of
+// the statistics of the pool, like MaxN and the decay, is kept.
+func (p *AdaptivePool[T]) LoadState(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read state: %w", err)
+	}
+	if len(b) < len(stateMagic)+1 || string(b[:len(stateMagic)]) != stateMagic {
+		return errors.New("AdaptivePool.LoadState: invalid format")
+	}
+	if v := b[len(stateMagic)]; v != stateVersion {
+		return fmt.Errorf("AdaptivePool.LoadState: unsupported version %d", v)
+	}
+	var st Stats
+	if err := st.UnmarshalBinary(b[len(stateMagic)+1:]); err != nil {
+		return fmt.Errorf("unmarshal stats: %w", err)
+	}
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	cur, ok := p.stats.(*Stats)
+	if !ok {
+		return errors.New("AdaptivePool.LoadState: unsupported StatsProvider")
+	}
+	st.setConfig(cur)
+	*cur = st
+	p.storeRStats()
+	return nil
+}
+
+// Seed replaces the statistics of the pool as if `n` items with sizes of the
+// given Mean and (Population) Standard Deviation had been put, so that the
+// first created items are already well-sized, without needing to create and
+// Put representative items. The value of `n` is capped to MaxN, and the
+// configuration of the statistics, like MaxN and the decay, is kept. See
+// [NewStatsSeed] for details about the arguments. If the pool uses a
+// custom [StatsProvider], then it is Reset and pushed `n` values alternating
+// between `mean - stdDev` and `mean + stdDev`, and `mean` last if `n` is odd.
+func (p *AdaptivePool[T]) Seed(mean, stdDev, n float64) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	maxN := p.stats.MaxN()
+	if maxN >= 1 {
+		n = min(n, maxN)
+	}
+	if cur, ok := p.stats.(*Stats); ok {
+		st := NewStatsSeed(n, mean, stdDev)
+		st.setConfig(cur)
+		*cur = st
+	} else {
+		p.stats.Reset()
+		p.stats.SetMaxN(maxN)
+		if n < 2 || math.IsNaN(stdDev) {
+			stdDev = 0
+		}
+		pairs := int(n) / 2
+		for i := 0; i < pairs; i++ {
+			p.stats.Push(mean - stdDev)
+			p.stats.Push(mean + stdDev)
+		}
+		if int(n)%2 == 1 {
+			p.stats.Push(mean)
+		}
+	}
+	p.storeRStats()
+}
+
+// GobEncode is part of the implementation of the [encoding/gob.GobEncoder]
+// interface. It encodes the statistics of the pool as with SaveState, so that
+// a pool can be warm started by decoding into it. The pooled items and the
+// configuration, like the PoolItemProvider, are not encoded.
+func (p *AdaptivePool[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.SaveState(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode is part of the implementation of the [encoding/gob.GobDecoder]
+// interface. It restores the statistics as with LoadState, so the pool must
+// have been created with New or similar, with the PoolItemProvider and MaxN
+// supplied again, which is kept.
+func (p *AdaptivePool[T]) GobDecode(data []byte) error {
+	return p.LoadState(bytes.NewReader(data))
+}
+
+// AggregateStats returns the result of merging the statistics of all the given
+// pools with [Stats.Merge], which is useful to get a single view of a set of
+// sharded pools. Pools without observations don't affect the result. If all the
+// pools have a MaxN, then the MaxN of the result is their sum, which is also
+// the maximum value that N can have in the result. Otherwise, the MaxN of the
+// result is zero (i.e. unbounded). This is the same rule as with
+// [MergeStats].
+func AggregateStats[T any](pools ...*AdaptivePool[T]) Stats {
+	var ret Stats
+	var maxN float64
+	bounded := len(pools) > 0
+	for _, p := range pools {
+		st := p.Stats()
+		ret.Merge(st)
+		if st.MaxN() < 1 {
+			bounded = false
+		}
+		maxN += st.MaxN()
+	}
+	if bounded {
+		ret.SetMaxN(maxN)
+	}
+	return ret
+}
+
+// CreateSize returns the size of the item that would be created if Get found
+// the pool empty, without creating it. It returns NaN if the PoolItemProvider
+// does not implement [CreateSizer].
+func (p *AdaptivePool[T]) CreateSize() float64 {
+	if p.createSizer == nil {
+		return math.NaN()
+	}
+	return p.createSizer.CreateSize(p.spikeCreateStats())
 }
 
 // Get returns a new object from the pool, allocating it from the
 // PoolItemProvider if needed.
 func (p *AdaptivePool[T]) Get() T {
-	return p.pool.Get().(T)
+	p.gets.Add(1)
+	if x, ok := p.TryGet(); ok {
+		return x
+	}
+	return p.new()
+}
+
+// GetWait is like Get, but if there are no items available in the pool then it
+// waits until one is put back or `ctx` is done. In the latter case, it returns
+// the context error, unless [AdaptivePool.SetWaitAllocate] was called with
+// true, in which case a new item is created and a nil error is returned. Note
+// that items in the pool can be removed by the garbage collector at any time,
+// and an item put back by another goroutine may not be immediately visible, so
+// `ctx` should always have a deadline.
+func (p *AdaptivePool[T]) GetWait(ctx context.Context) (T, error) {
+	p.gets.Add(1)
+	p.waiters.Add(1)
+	defer p.waiters.Add(-1)
+	for {
+		// the channel must be obtained before trying to get an item, otherwise
+		// we could miss the signal of an item put between both operations
+		ch := p.waitChan()
+		if x, ok := p.TryGet(); ok {
+			return x, nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			if p.waitAlloc {
+				return p.new(), nil
+			}
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// SetWaitAllocate sets whether GetWait should create a new item instead of
+// returning an error when its context is done. It may not be changed
+// concurrently with calls to GetWait.
+func (p *AdaptivePool[T]) SetWaitAllocate(allocate bool) {
+	p.waitAlloc = allocate
+}
+
+// TryGet is like Get, but it never creates a new item. If there are no items
+// available in the pool, then it returns the zero value and false. The internal
+// sync.Pool has no `New` function, which is instead handled by Get, so this
+// doesn't need any additional synchronization.
+func (p *AdaptivePool[T]) TryGet() (T, bool) {
+	p.checkClosed()
+	x, ok := p.loadPool().Get().(T)
+	if p.maxItems > 0 {
+		if ok {
+			p.retained.Add(-1)
+		} else {
+			// items were discarded by sync.Pool, so start counting again
+			p.retained.Store(0)
+		}
+	}
+	return x, ok
+}
+
+func (p *AdaptivePool[T]) waitChan() <-chan struct{} {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+	if p.waitCh == nil {
+		p.waitCh = make(chan struct{})
+	}
+	return p.waitCh
+}
+
+// retain puts the item in the pool and wakes up any goroutines blocked in
+// GetWait.
+func (p *AdaptivePool[T]) retain(x T) {
+	p.loadPool().Put(x)
+	if p.waiters.Load() > 0 {
+		p.waitMu.Lock()
+		if p.waitCh != nil {
+			close(p.waitCh)
+			p.waitCh = nil
+		}
+		p.waitMu.Unlock()
+	}
 }
 
 // Put updates the internal statistics with the size of the object and puts
 // it back to the pool if [PoolItemProvider.Accept] allows it. Items with a
-// negative size will not be put back into the pool.
+// negative size will not be put back into the pool. If the PoolItemProvider
+// implements [SizeAccepter], then it is used instead of Sizeof and Accept, and
+// if it implements [ResettingProvider], then retained items are reset.
 func (p *AdaptivePool[T]) Put(x T) {
-	s := p.provider.Sizeof(x)
-	if s < 0 {
-		return
+	p.put(x, false, nil, nil)
+}
+
+// PutForce is like Put, but the item is always put back into the pool,
+// regardless of what [PoolItemProvider.Accept] would decide. This is useful for
+// items that the caller knows are well-sized, like one that was just created
+// with the right size. Statistics are updated the same as with Put, and items
+// with a negative size will still not be put back into the pool.
+func (p *AdaptivePool[T]) PutForce(x T) {
+	p.put(x, true, nil, nil)
+}
+
+// PutObserve is like Put, but it also returns whether the item was retained,
+// and the sizes that [CreateSizer.CreateSize] returns with the statistics
+// before and after updating them with the size of the item, which is useful to
+// track the volatility of the sizes of created items. Spike detection is not
+// considered. The sizes are NaN if the PoolItemProvider doesn't implement
+// CreateSizer, and they are the same if the statistics were not updated.
+func (p *AdaptivePool[T]) PutObserve(x T) (
+	accepted bool,
+	createSizeBefore, createSizeAfter float64,
+) {
+	var obs putObservation
+	accepted = p.put(x, false, &obs, nil)
+	if p.createSizer == nil {
+		return accepted, math.NaN(), math.NaN()
+	}
+	if !obs.pushed {
+		obs.mean, obs.stdDev = p.createStats()
+		obs.newMean, obs.newStdDev = obs.mean, obs.stdDev
+	}
+	return accepted, p.createSizer.CreateSize(obs.mean, obs.stdDev),
+		p.createSizer.CreateSize(obs.newMean, obs.newStdDev)
+}
+
+// putObservation holds the statistics before and after a call to Push, with
+// the same precision as the ones passed to Create.
+type putObservation struct {
+	pushed                           bool
+	mean, stdDev, newMean, newStdDev float64
+}
+
+// put retains `x` as described in Put and PutForce. If `size` is not nil, then
+// it's used as the size of `x` instead of measuring it.
+func (p *AdaptivePool[T]) put(x T, force bool, obs *putObservation,
+	size *float64) bool {
+	p.checkClosed()
+	p.puts.Add(1)
+	var s float64
+	var accept bool
+	sizeAccepter := p.sizeAccepter
+	if p.strategy != nil || size != nil {
+		sizeAccepter = nil
+	}
+	if size != nil {
+		s = *size
+	} else if sizeAccepter != nil {
+		mean, stdDev := p.createStats()
+		s, accept = sizeAccepter.SizeAndAccept(mean, stdDev, x)
+	} else {
+		s = p.provider.Sizeof(x)
+	}
+	if s < 0 || p.prePut != nil && !p.prePut(s) {
+		p.drops.Add(1)
+		return false
+	}
+
+	mean, stdDev := p.writeThenRead(s, obs)
+	if !force && p.strategy != nil {
+		accept = p.strategy.Accept(mean, stdDev, s)
+	} else if !force && sizeAccepter == nil {
+		accept = p.provider.Accept(mean, stdDev, s)
+	}
+	if !force && !accept && p.onDrop != nil {
+		p.onDrop(s, mean, stdDev)
+	}
+	if accept && s > mean && p.pressure.Load() {
+		accept = false
 	}
-	mean, stdDev := p.writeThenRead(s)
-	if p.provider.Accept(mean, stdDev, s) {
-		p.pool.Put(x)
+	if (force || accept) && p.reserve() {
+		if p.resetter != nil {
+			p.resetter.Reset(x)
+		}
+		p.retain(x)
+		return true
 	}
+	p.drops.Add(1)
+	return false
+}
+
+// putSize is like Put, but the statistics are updated with `s` instead of the
+// size of `x`, and the decision to retain it is also made with `s`.
+func (p *AdaptivePool[T]) putSize(x T, s float64) bool {
+	return p.put(x, false, nil, &s)
+}
+
+// reserve returns whether there is room for one more item in the pool, and in
+// that case it counts it as retained.
+func (p *AdaptivePool[T]) reserve() bool {
+	if p.maxItems <= 0 {
+		return true
+	}
+	if p.retained.Add(1) > p.maxItems {
+		p.retained.Add(-1)
+		return false
+	}
+	return true
+}
+
+// SetMaxItems sets a limit to the number of items retained in the pool, which
+// is useful to bound the memory held by bursts of large items between garbage
+// collections. When the limit is reached, Put and PutForce drop items even if
+// they would be otherwise retained. A value of zero or less, the default,
+// disables the limit. It may not be changed concurrently with calls to Get or
+// Put.
+//
+// The number of retained items is counted by Put and decremented by Get, but
+// the underlying [sync.Pool] can also discard items on its own, like during a
+// garbage collection, without notice. To account for that, the count is reset
+// when Get finds no items in the pool. Hence, the limit is approximate: after
+// a garbage collection Put may drop items until the next call to Get, and since
+// sync.Pool keeps items per processor, Get may find no items while others still
+// hold some, which allows retaining more than `n` items for a while.
+func (p *AdaptivePool[T]) SetMaxItems(n int) {
+	p.maxItems = int64(n)
+}
+
+// SetOnDrop sets a function that is called in Put each time the
+// PoolItemProvider, or the AcceptStrategy if set, rejects an item, with its
+// size and the statistics used to make the decision. This is useful to tune
+// the policy of the PoolItemProvider, like its Threshold. It is called without
+// holding any locks, so it may use the pool. It may not be changed
+// concurrently with calls to Put.
+func (p *AdaptivePool[T]) SetOnDrop(f func(itemSize, mean, stdDev float64)) {
+	p.onDrop = f
 }
 
-func (p *AdaptivePool[T]) writeThenRead(s float64) (mean, stdDev float64) {
+// SetAcceptStrategy sets the policy used by Put to decide whether to retain an
+// item, instead of the Accept method of the PoolItemProvider, which is still
+// used to measure and create items. If the PoolItemProvider implements
+// [SizeAccepter], then Put uses its Sizeof method instead. A nil `s` restores
+// the default behavior, which for the providers in this package that assume a
+// Normal Distribution is the same as a [NormalBand] with their thresholds. It
+// may not be changed concurrently with calls to Put.
+func (p *AdaptivePool[T]) SetAcceptStrategy(s AcceptStrategy) {
+	p.strategy = s
+}
+
+// SetPrePut sets a function that is called in Put with the size of each item,
+// before updating the statistics. If it returns false, then the statistics are
+// not updated and the item is dropped. This allows excluding known anomalous
+// items from the statistics, like the bodies of health check requests. It
+// applies also to PutForce. It may not be changed concurrently with calls to
+// Put.
+func (p *AdaptivePool[T]) SetPrePut(f func(size float64) bool) {
+	p.prePut = f
+}
+
+func (p *AdaptivePool[T]) writeThenRead(
+	s float64,
+	obs *putObservation,
+) (mean, stdDev float64) {
 	// this could be changed to a TryLock and return an additional false on lock
 	// failure, in which case the item would also not be put in the pool
 	p.statsMu.Lock()
 	defer p.statsMu.Unlock()
+	if obs != nil {
+		obs.mean, obs.stdDev = p.stats.Mean(), p.stats.StdDev()
+		if !p.precise {
+			obs.mean = float64(float32(obs.mean))
+			obs.stdDev = float64(float32(obs.stdDev))
+		}
+	}
 	p.stats.Push(s)
-	mn32, sd32 := float32(p.stats.Mean()), float32(p.stats.StdDev())
+	p.status.push(p.stats)
+	if p.hist != nil {
+		p.hist.AddSample(s)
+	}
+	mean, stdDev = p.storeRStats()
+	p.rStatsGen.Add(1)
+	if p.spikeAlpha > 0 {
+		p.detectSpike(s)
+	}
+	if p.precise {
+		mean, stdDev = p.stats.Mean(), p.stats.StdDev()
+	}
+	if obs != nil {
+		obs.pushed, obs.newMean, obs.newStdDev = true, mean, stdDev
+	}
+	return mean, stdDev
+}
+
+// SetSpikeDetection enables a short-term detector of bursts of large items,
+// during which new items are created as if the mean was the recent mean
+// instead, so that they are not under-sized while the long-term mean catches
+// up. The recent mean is an exponentially weighted moving average, where
+// `alpha` is the weight of each new size, in the range (0, 1]. A spike is
+// detected while the recent mean is greater than `factor` times the long-term
+// mean, and it relaxes as soon as it's not. A value of `alpha` less than or
+// equal to zero disables spike detection. It may not be changed concurrently
+// with any other method.
+func (p *AdaptivePool[T]) SetSpikeDetection(alpha, factor float64) {
+	p.spikeAlpha, p.spikeFactor = min(max(alpha, 0), 1), factor
+	p.recent = math.NaN()
+	p.spikeMean.Store(0)
+}
+
+// detectSpike updates the recent mean with the size `s`. It must be called with
+// statsMu held for writing.
+func (p *AdaptivePool[T]) detectSpike(s float64) {
+	if math.IsNaN(p.recent) {
+		p.recent = s
+	} else {
+		p.recent = math.FMA(p.spikeAlpha, s-p.recent, p.recent)
+	}
+	var bits uint64
+	if p.recent > p.spikeFactor*p.stats.Mean() {
+		bits = math.Float64bits(p.recent)
+	}
+	p.spikeMean.Store(bits)
+}
+
+// spikeCreateStats is like createStats, but it replaces the mean with the
+// recent mean during a spike, or with the quantile set with SetHistogram.
+func (p *AdaptivePool[T]) spikeCreateStats() (mean, stdDev float64) {
+	if p.histQuant > 0 {
+		if q := p.Quantile(p.histQuant); !math.IsNaN(q) {
+			return q, 0
+		}
+	}
+	mean, stdDev = p.createStats()
+	if bits := p.spikeMean.Load(); bits != 0 {
+		mean = math.Float64frombits(bits)
+	}
+	return mean, stdDev
+}
+
+// SetHistogram makes Put add the measured sizes to `h`, which allows
+// estimating the quantiles of the sizes with [AdaptivePool.Quantile]. If
+// `createQuantile` is in the range (0, 1], then new items are created with the
+// estimated `createQuantile`-quantile as the mean and a zero standard
+// deviation, e.g. 0.95 to create items that fit 95% of the sizes, which
+// requires acquiring a read lock. This takes precedence over spike detection,
+// and it falls back to the usual statistics until a size is measured. A nil
+// `h` disables it. The Histogram is cleared by Reset. It may not be changed
+// concurrently with any other method.
+func (p *AdaptivePool[T]) SetHistogram(h *Histogram, createQuantile float64) {
+	p.hist = h
+	p.histQuant = 0
+	if h != nil && createQuantile > 0 && createQuantile <= 1 {
+		p.histQuant = createQuantile
+	}
+}
+
+// Quantile returns the `q`-quantile estimated by the Histogram set with
+// SetHistogram. It returns NaN if there is no Histogram, or as described in
+// [Histogram.Quantile].
+func (p *AdaptivePool[T]) Quantile(q float64) float64 {
+	p.statsMu.RLock()
+	defer p.statsMu.RUnlock()
+	if p.hist == nil {
+		return math.NaN()
+	}
+	return p.hist.Quantile(q)
+}
+
+// SetPrecise sets whether the PoolItemProvider should receive the mean and
+// standard deviation with full precision. By default, they are stored as 32bit
+// floating point numbers in a single atomic value, so that creating new items
+// doesn't require locking, which may cause a small rounding error for very
+// large sizes. In precise mode, creating a new item requires acquiring a read
+// lock instead. It may not be changed concurrently with any other method.
+func (p *AdaptivePool[T]) SetPrecise(precise bool) {
+	p.precise = precise
+}
+
+// storeRStats updates the lock-free copy of the stats. It must be called with
+// statsMu held for writing.
+func (p *AdaptivePool[T]) storeRStats() (mean, stdDev float64) {
+	mn32 := canonicalNaN32(float32(p.stats.Mean()))
+	sd32 := canonicalNaN32(float32(p.stats.StdDev()))
 	u64 := encodeBits(mn32, sd32)
 	p.rStats.Store(u64)
 
 	// reduced precision for consistency with the values passed to `Create`
-	return float64(mn32), float64(sd32)
+	return float32To64(mn32), float32To64(sd32)
+}
+
+// Heap usage ratios, relative to the heap size that will trigger the next GC,
+// used to detect high memory pressure. There is a gap between them to avoid
+// rapidly toggling between states.
+const (
+	gcHighPressure = 0.9
+	gcLowPressure  = 0.7
+)
+
+// EnableGCAwareness starts a goroutine that checks the heap usage every `poll`
+// interval. When the heap usage is close to triggering the next GC, Put will
+// drop items larger than the mean that would have otherwise been accepted,
+// until the heap usage decreases. Note that this uses [runtime.ReadMemStats],
+// which stops the world for a short period, so `poll` should not be too short.
+// Intervals in the order of seconds are recommended. Calling it again replaces
+// the previous interval. It may not be called concurrently with
+// DisableGCAwareness.
+func (p *AdaptivePool[T]) EnableGCAwareness(poll time.Duration) {
+	p.DisableGCAwareness()
+	p.gcStop, p.gcDone = make(chan struct{}), make(chan struct{})
+	go p.gcAwarenessLoop(p.clock.NewTicker(poll), p.gcStop, p.gcDone)
+}
+
+// DisableGCAwareness stops the goroutine started by EnableGCAwareness, if any,
+// and waits for it to finish.
+func (p *AdaptivePool[T]) DisableGCAwareness() {
+	if p.gcStop != nil {
+		close(p.gcStop)
+		<-p.gcDone
+		p.gcStop, p.gcDone = nil, nil
+	}
+	p.pressure.Store(false)
+}
+
+// StartDecay starts a goroutine that calls [Stats.Forget] with `factor` every
+// `interval`, so that the statistics also forget old values as time passes,
+// and not only as new values are pushed. Otherwise, after a quiet period, the
+// first items put are judged by the stale statistics of the last burst. The
+// StatsProvider must implement a `Forget(float64)` method like *Stats does, or
+// this is a no-op. Calling it again replaces the previous interval and factor.
+// It may not be called concurrently with StopDecay.
+func (p *AdaptivePool[T]) StartDecay(interval time.Duration, factor float64) {
+	p.StopDecay()
+	if _, ok := p.stats.(forgetter); !ok {
+		return
+	}
+	p.decayStop, p.decayDone = make(chan struct{}), make(chan struct{})
+	go p.decayLoop(p.clock.NewTicker(interval), factor, p.decayStop,
+		p.decayDone)
 }
 
-func (p *AdaptivePool[T]) new() any {
+// StopDecay stops the goroutine started by StartDecay, if any, and waits for it
+// to finish.
+func (p *AdaptivePool[T]) StopDecay() {
+	if p.decayStop != nil {
+		close(p.decayStop)
+		<-p.decayDone
+		p.decayStop, p.decayDone = nil, nil
+	}
+}
+
+// forgetter is implemented by StatsProviders that support StartDecay.
+type forgetter interface {
+	Forget(factor float64)
+}
+
+func (p *AdaptivePool[T]) decayLoop(t ticker, factor float64,
+	stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C():
+			p.statsMu.Lock()
+			p.stats.(forgetter).Forget(factor)
+			p.statsMu.Unlock()
+		}
+	}
+}
+
+// Close stops any background goroutines of the pool, like the ones started by
+// EnableGCAwareness and StartDecay, so that it can be safely discarded. After
+// that, any call to a Get or Put method panics, to catch uses after Close.
+// Subsequent calls to Close are a no-op. Note that names published with
+// PublishExpvar can't be unregistered, since the expvar package doesn't allow
+// it, and they will keep reporting the last metrics of the pool. It may not be
+// called concurrently with the methods that start or stop them.
+func (p *AdaptivePool[T]) Close() error {
+	if !p.closed.Swap(true) {
+		p.DisableGCAwareness()
+		p.StopDecay()
+	}
+	return nil
+}
+
+// errPoolClosed is the panic value when using a closed pool.
+var errPoolClosed = errors.New("adaptivepool: use of closed AdaptivePool")
+
+func (p *AdaptivePool[T]) checkClosed() {
+	if p.closed.Load() {
+		panic(errPoolClosed)
+	}
+}
+
+func (p *AdaptivePool[T]) gcAwarenessLoop(t ticker, stop <-chan struct{},
+	done chan<- struct{}) {
+	defer close(done)
+	defer t.Stop()
+	m := new(runtime.MemStats)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C():
+			p.pollMemStats(m)
+		}
+	}
+}
+
+func (p *AdaptivePool[T]) pollMemStats(m *runtime.MemStats) {
+	p.memStats.ReadMemStats(m)
+	if m.NextGC == 0 {
+		return
+	}
+	switch ratio := float64(m.HeapAlloc) / float64(m.NextGC); {
+	case ratio >= gcHighPressure:
+		p.pressure.Store(true)
+	case ratio < gcLowPressure:
+		p.pressure.Store(false)
+	}
+}
+
+// memStatsReader allows injecting fake memory statistics in tests.
+type memStatsReader interface {
+	ReadMemStats(*runtime.MemStats)
+}
+
+type runtimeMemStats struct{}
+
+func (runtimeMemStats) ReadMemStats(m *runtime.MemStats) {
+	runtime.ReadMemStats(m)
+}
+
+func (p *AdaptivePool[T]) new() T {
+	p.misses.Add(1)
+	mean, stdDev := p.spikeCreateStats()
+	if p.onCreate != nil {
+		size := math.NaN()
+		if p.createSizer != nil {
+			size = p.createSizer.CreateSize(mean, stdDev)
+		}
+		p.onCreate(mean, stdDev, size)
+	}
+	return p.provider.Create(mean, stdDev)
+}
+
+// SetOnCreate sets a function that is called each time a new item is created,
+// with the arguments passed to [PoolItemProvider.Create] and the size of the
+// created item, which is NaN if the PoolItemProvider does not implement
+// [CreateSizer]. This is useful to debug the sizing decisions. It may not be
+// changed concurrently with calls to any Get method.
+func (p *AdaptivePool[T]) SetOnCreate(f func(mean, stdDev, size float64)) {
+	p.onCreate = f
+}
+
+// createStats returns the mean and standard deviation for creating new items.
+func (p *AdaptivePool[T]) createStats() (mean, stdDev float64) {
+	if p.precise {
+		p.statsMu.RLock()
+		defer p.statsMu.RUnlock()
+		return p.stats.Mean(), p.stats.StdDev()
+	}
+	return p.FastStats()
+}
+
+// FastStats returns the Mean and StdDev of the pool without locking, which is
+// cheaper than Stats for frequent reads, like in dashboards. They are the
+// values passed to [PoolItemProvider.Create] unless the pool is in precise
+// mode, which are stored with float32 precision, and they may lag behind a
+// concurrent Put.
+func (p *AdaptivePool[T]) FastStats() (mean, stdDev float64) {
 	mn32, sd32 := decodeBits(p.rStats.Load())
-	return p.provider.Create(float64(mn32), float64(sd32))
+	return float32To64(mn32), float32To64(sd32)
+}
+
+// StatsGeneration returns the number of times that the values returned by
+// FastStats have been updated by a Put, including PutForce and the like. It is
+// not reset by Reset. Comparing it before and after a call to FastStats, or
+// against the generation seen by another goroutine, helps to debug how stale
+// the lock-free statistics used to create new items may be.
+func (p *AdaptivePool[T]) StatsGeneration() uint64 {
+	return p.rStatsGen.Load()
 }
 
+// normalCreateSize is never negative, which could only happen with statistics
+// from negative sizes, like from a seed, since those are dropped by Put.
 func normalCreateSize(mean, stdDev, thresh float64) float64 {
 	if math.IsNaN(stdDev) {
-		return mean
+		return max(mean, 0)
 	}
-	return mean + thresh*stdDev
+	return max(mean+thresh*stdDev, 0)
 }
 
 func normalAccept(mean, stdDev, thresh, itemSize float64) bool {
-	sdThresh := thresh * stdDev
-	return mean-sdThresh <= itemSize && itemSize <= mean+sdThresh ||
+	return normalAcceptBand(mean, stdDev, thresh, thresh, itemSize)
+}
+
+// normalAcceptBand accepts any item above the lower bound if `upper` is +Inf,
+// even if `stdDev` is zero.
+func normalAcceptBand(mean, stdDev, lower, upper, itemSize float64) bool {
+	return mean-lower*stdDev <= itemSize &&
+		(math.IsInf(upper, 1) || itemSize <= mean+upper*stdDev) ||
 		math.IsNaN(stdDev)
 }
 
+// bandThreshold returns `thresh` if positive, and `fallback` otherwise.
+func bandThreshold(thresh, fallback float64) float64 {
+	if thresh > 0 {
+		return thresh
+	}
+	return fallback
+}
+
+// upperThreshold is like bandThreshold, but it returns +Inf if `unbounded`.
+func upperThreshold(thresh, fallback float64, unbounded bool) float64 {
+	if unbounded {
+		return math.Inf(1)
+	}
+	return bandThreshold(thresh, fallback)
+}
+
 func encodeBits(lo, hi float32) uint64 {
 	return uint64(math.Float32bits(lo)) +
 		uint64(math.Float32bits(hi))<<32
@@ -194,7 +1447,40 @@ func decodeBits(u64 uint64) (lo, hi float32) {
 		math.Float32frombits(uint32(u64 >> 32))
 }
 
+// nan32Bits is the canonical quiet NaN for float32.
+const nan32Bits = 0x7fc00000
+
+// canonicalNaN32 returns `f`, or the canonical quiet NaN if `f` is a NaN.
+// NaNs have many bit patterns, and some of them are signaling NaNs, so they
+// are replaced to make sure that they survive being stored with encodeBits.
+func canonicalNaN32(f float32) float32 {
+	if f != f {
+		return math.Float32frombits(nan32Bits)
+	}
+	return f
+}
+
+// float32To64 converts `f` to a float64, returning math.NaN() if it's a NaN.
+func float32To64(f float32) float64 {
+	if f != f {
+		return math.NaN()
+	}
+	return float64(f)
+}
+
 type pool interface {
 	Get() any
 	Put(any)
 }
+
+func newSyncPool() pool {
+	return new(sync.Pool)
+}
+
+func (p *AdaptivePool[T]) loadPool() pool {
+	return *p.pool.Load()
+}
+
+func (p *AdaptivePool[T]) setPool(pl pool) {
+	p.pool.Store(&pl)
+}
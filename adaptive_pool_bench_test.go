@@ -0,0 +1,113 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func BenchmarkSmallSlices(b *testing.B) {
+	// Storing a slice in a sync.Pool requires boxing its header, which costs
+	// one allocation per Put. Compare with:
+	//	go test -run=- -bench=SmallSlices -benchmem -count=20 | benchstat -col=/provider -
+	const size = 8
+	thresh := 2.0
+
+	b.Run("provider=NormalSlice", func(b *testing.B) {
+		pool := New[[]float64](NormalSlice[float64]{
+			MinCap:    size,
+			Threshold: thresh,
+		}, 500)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v := pool.Get()[:0]
+			for j := 0; j < size; j++ {
+				v = append(v, float64(j))
+			}
+			pool.Put(v)
+		}
+	})
+
+	b.Run("provider=NormalSlicePtr", func(b *testing.B) {
+		pool := New[*[]float64](NormalSlicePtr[float64]{
+			NormalSlice: NormalSlice[float64]{
+				MinCap:    size,
+				Threshold: thresh,
+			},
+		}, 500)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v := pool.Get()
+			*v = (*v)[:0]
+			for j := 0; j < size; j++ {
+				*v = append(*v, float64(j))
+			}
+			pool.Put(v)
+		}
+	})
+}
+
+func BenchmarkPut(b *testing.B) {
+	// Compare with:
+	//	go test -run=- -bench=Put -count=20 | benchstat -col=/path -
+	var sizeofCalls, sizeAndAcceptCalls int
+	provider := NormalBytesBuffer{Threshold: 2}
+	combined := sizeAcceptBuffer{
+		NormalBytesBuffer:  provider,
+		sizeofCalls:        &sizeofCalls,
+		sizeAndAcceptCalls: &sizeAndAcceptCalls,
+	}
+	item := bytes.NewBuffer(make([]byte, 512))
+
+	b.Run("path=two-calls", benchPut[*bytes.Buffer](provider, item))
+	b.Run("path=combined", benchPut[*bytes.Buffer](combined, item))
+}
+
+func benchPut[T any](p PoolItemProvider[T], item T) func(b *testing.B) {
+	return func(b *testing.B) {
+		pool := New(p, 500)
+		pool.setPool(nopPool{})
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pool.Put(item)
+		}
+	}
+}
+
+func BenchmarkShardedPut(b *testing.B) {
+	// Compare with:
+	//	go test -run=- -bench=ShardedPut -cpu=8 -count=20 | benchstat -col=/pool -
+	item := bytes.NewBuffer(make([]byte, 512))
+	provider := NormalBytesBuffer{Threshold: 2}
+
+	b.Run("pool=AdaptivePool", func(b *testing.B) {
+		pool := New[*bytes.Buffer](provider, 500)
+		pool.setPool(nopPool{})
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				pool.Put(item)
+			}
+		})
+	})
+
+	b.Run("pool=ShardedPool", func(b *testing.B) {
+		pool := NewSharded[*bytes.Buffer](provider, 500, 8)
+		for _, shard := range pool.shards {
+			shard.setPool(nopPool{})
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				pool.Put(item)
+			}
+		})
+	})
+}
+
+// nopPool drops all items.
+type nopPool struct{}
+
+func (nopPool) Get() any  { return nil }
+func (nopPool) Put(x any) {}
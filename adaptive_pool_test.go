@@ -2,15 +2,37 @@ package adaptivepool
 
 import (
 	"bytes"
+	"context"
+	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 var (
-	_ PoolItemProvider[[]byte]        = NormalSlice[byte]{}
-	_ PoolItemProvider[*bytes.Buffer] = NormalBytesBuffer{}
+	_ PoolItemProvider[[]byte]           = NormalSlice[byte]{}
+	_ PoolItemProvider[*[]byte]          = NormalSlicePtr[byte]{}
+	_ PoolItemProvider[*bytes.Buffer]    = NormalBytesBuffer{}
+	_ PoolItemProvider[map[int]int]      = NormalMap[int, int]{}
+	_ PoolItemProvider[*strings.Builder] = NormalStringsBuilder{}
+	_ PoolItemProvider[chan int]         = NormalChan[int]{}
+
+	_ CreateSizer = NormalSlice[byte]{}
+	_ CreateSizer = NormalSlicePtr[byte]{}
+	_ CreateSizer = NormalBytesBuffer{}
+	_ CreateSizer = NormalMap[int, int]{}
+	_ CreateSizer = NormalStringsBuilder{}
+	_ CreateSizer = NormalChan[int]{}
+
+	_ StatsProvider = new(Stats)
 )
 
 func TestAdaptivePool(t *testing.T) {
@@ -63,6 +85,56 @@ func TestAdaptivePool(t *testing.T) {
 		x.assertStats(15, 32, 16)
 	})
 
+	t.Run("forced put", func(t *testing.T) {
+		t.Parallel()
+		v := func(n int) []int {
+			return make([]int, n)
+		}
+		capv := func(v []int) float64 {
+			return float64(cap(v))
+		}
+
+		x := newAdaptivePoolAsserter(t, NormalSlice[int]{
+			Threshold: 1,
+		}, capv)
+		x.assertPutForce(nil, true) // negative size is still a nop
+		x.assertStats(0, 0, math.NaN())
+		x.assertPut(v(10), false)      // n=1 ; mean=10   ; stdDev=NaN
+		x.assertPut(v(10), false)      // n=2 ; mean=10   ; stdDev=0
+		x.assertPut(v(10), false)      // n=3 ; mean=10   ; stdDev=0
+		x.assertPutForce(v(20), false) // n=4 ; mean=12.5 ; stdDev=4.3
+		x.assertStats(4, 12.5, 4.3)
+		x.assertPut(v(20), true)       // n=5 ; mean=14   ; stdDev=4.8
+		x.assertPutForce(v(90), false) // n=6 ; mean=26.7 ; stdDev=28.7
+		x.assertStats(6, 26.7, 28.7)
+	})
+
+	t.Run("slice pointers", func(t *testing.T) {
+		t.Parallel()
+		v := func(n int) *[]float64 {
+			s := make([]float64, n)
+			return &s
+		}
+		capv := func(v *[]float64) float64 {
+			return float64(cap(*v))
+		}
+
+		x := newAdaptivePoolAsserter(t, NormalSlicePtr[float64]{
+			NormalSlice: NormalSlice[float64]{
+				MinCap:    2,
+				Threshold: 1,
+			},
+		}, capv)
+		x.assertPut(nil, true) // should be a nop
+		x.assertStats(0, 0, math.NaN())
+		x.assertGet(2)
+		x.assertPut(v(10), false) // n=1 ; mean=10   ; stdDev=NaN
+		x.assertPut(v(10), false) // n=2 ; mean=10   ; stdDev=0
+		x.assertPut(v(20), true)  // n=3 ; mean=13.3 ; stdDev=4.7
+		x.assertStats(3, 13.3, 4.7)
+		x.assertGet(18)
+	})
+
 	t.Run("test data from file", func(t *testing.T) {
 		t.Parallel()
 		const thresh = 2.5
@@ -110,6 +182,1229 @@ func TestAdaptivePool(t *testing.T) {
 	})
 }
 
+func TestNormalMap(t *testing.T) {
+	t.Parallel()
+	v := func(n int) map[int]int {
+		m := make(map[int]int, n)
+		for i := 0; i < n; i++ {
+			m[i] = i
+		}
+		return m
+	}
+
+	ap, sp := newStackAdaptivePool[map[int]int](NormalMap[int, int]{
+		MinCap:    4,
+		Threshold: 1,
+	}, 0)
+	ap.Put(nil) // should be a nop
+	equal(t, 0, sp.Len(), "nil map should not be retained")
+	equal(t, 4, ap.CreateSize(), "CreateSize of empty pool")
+	m := ap.Get()
+	equal(t, true, m != nil && len(m) == 0, "created map should be empty")
+
+	// n=1 ; mean=10 ; stdDev=NaN
+	// n=2 ; mean=10 ; stdDev=0
+	// n=3 ; mean=10 ; stdDev=0
+	// n=4 ; mean=12.5 ; stdDev=4.3
+	// n=5 ; mean=14 ; stdDev=4.8
+	// n=6 ; mean=15 ; stdDev=5
+	for i, c := range []struct {
+		size       int
+		createSize float64
+	}{
+		{10, 10}, {10, 10}, {10, 10}, {20, 16}, {20, 18}, {20, 20},
+	} {
+		ap.Put(v(c.size))
+		equal(t, c.createSize, ap.CreateSize(), "[#%d] CreateSize", i)
+	}
+	equal(t, 4, sp.Len(), "retained maps")
+	equal(t, 20, len(ap.Get()), "maps should be retained with their entries")
+
+	ap, sp = newStackAdaptivePool[map[int]int](NormalMap[int, int]{
+		Clear: true,
+	}, 0)
+	ap.Put(v(10))
+	st := ap.Stats()
+	equal(t, 10, st.Mean(), "size should be measured before clearing")
+	equal(t, 1, sp.Len(), "retained maps")
+	equal(t, 0, len(ap.Get()), "maps should be cleared")
+}
+
+func TestNormalChan(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[chan int](NormalChan[int]{
+		MinCap:    4,
+		Threshold: 1,
+	}, 0)
+	ap.Put(nil) // should be a nop
+	equal(t, 0, sp.Len(), "nil channel should not be retained")
+	equal(t, 4, cap(ap.Get()), "capacity of first created channel")
+
+	// same sizes as in TestNormalMap
+	for i, c := range []struct {
+		size       int
+		createSize float64
+	}{
+		{10, 10}, {10, 10}, {10, 10}, {20, 16}, {20, 18}, {20, 20},
+	} {
+		ap.Put(make(chan int, c.size))
+		equal(t, c.createSize, ap.CreateSize(), "[#%d] CreateSize", i)
+	}
+	equal(t, 4, sp.Len(), "retained channels")
+	for sp.Len() > 0 {
+		sp.Get()
+	}
+	equal(t, 20, cap(ap.Get()), "capacity of created channel")
+
+	ch := make(chan int, 20)
+	ch <- 1
+	ap.Put(ch)
+	zero(t, sp.Len(), "channels not drained should not be retained")
+	st := ap.Stats()
+	equal(t, 6, st.N(), "channels not drained should not be measured")
+}
+
+func TestNormalStringsBuilder(t *testing.T) {
+	t.Parallel()
+	const thresh = 2.5
+	v := func(n int) *strings.Builder {
+		b := new(strings.Builder)
+		b.WriteString(strings.Repeat("x", n))
+		return b
+	}
+	capv := func(v *strings.Builder) float64 {
+		return float64(v.Cap())
+	}
+
+	x := newAdaptivePoolAsserter(t, NormalStringsBuilder{
+		Threshold: thresh,
+	}, capv)
+	x.assertPut(nil, true) // should be a nop
+	x.assertPut(new(strings.Builder), true)
+	x.assertStats(0, 0, math.NaN())
+	x.assertGet(0)
+
+	// Grow may round up the capacity to the size class of the allocation
+	assertGrown := func(expectedSize float64) {
+		t.Helper()
+		b := x.ap.Get()
+		equal(t, 0, b.Len(), "created builder should be empty")
+		if got := float64(b.Cap()); got < expectedSize {
+			t.Fatalf("expected builder grown to at least %v, got %v",
+				expectedSize, got)
+		}
+	}
+
+	values := make([]float64, 3)
+	cr := csvTestDataReader(t)
+	var i float64
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		i++
+		zero(t, err, "read CSV record #%d", i)
+		err = parseFloats(rec, values)
+		zero(t, err, "parse floats from CSV record #%d; record: %v", i, rec)
+
+		x.ap.Put(v(int(values[0])))
+		if i == 1 || i == 100 {
+			assertGrown(x.ap.CreateSize())
+		}
+	}
+	x.assertStats(i, values[1], values[2])
+	expectedSize := normalCreateSize(values[1], values[2], thresh)
+	assertGrown(float64(int(expectedSize)))
+}
+
+func TestNormalStringsBuilderRetain(t *testing.T) {
+	t.Parallel()
+	v := func(s string) *strings.Builder {
+		b := new(strings.Builder)
+		b.WriteString(s)
+		return b
+	}
+
+	ap, sp := newStackAdaptivePool[*strings.Builder](NormalStringsBuilder{
+		Threshold: 1,
+	}, 0)
+	for range 4 {
+		ap.Put(v("abcde"))
+	}
+	b := v(strings.Repeat("x", 100))
+	ap.Put(b)
+	equal(t, 4, sp.Len(), "the outlier should be dropped")
+	equal(t, 100, b.Len(), "dropped builder should not be reset")
+
+	b = v("abcde")
+	str := b.String()
+	origCap := b.Cap()
+	ap.Put(b)
+	equal(t, 5, sp.Len(), "builder should be retained")
+	b = ap.Get()
+	equal(t, 0, b.Len(), "retained builder should be reset")
+	equal(t, true, b.Cap() >= origCap, "should keep its capacity: %v",
+		b.Cap())
+	b.WriteString("xyz")
+	equal(t, "abcde", str, "strings from the reset builder should not change")
+}
+
+func TestNewChecked(t *testing.T) {
+	t.Parallel()
+
+	ap, err := NewChecked[int](nil, 0)
+	equal(t, true, errors.Is(err, ErrNilProvider), "should fail with nil")
+	zero(t, ap, "should return nil on error")
+
+	ap, err = NewChecked[int](intProvider{}, 500)
+	zero(t, err, "should succeed with a provider")
+	st := ap.Stats()
+	equal(t, 500, st.MaxN(), "MaxN")
+
+	_, err = NewChecked[[]byte](NormalSlice[byte]{Threshold: -1}, 500)
+	equal(t, true, errors.Is(err, ErrInvalidThreshold),
+		"should fail with a negative threshold")
+
+	defer func() {
+		equal(t, true, recover() != nil, "New should panic with nil")
+	}()
+	New[int](nil, 0)
+}
+
+func TestValidateThreshold(t *testing.T) {
+	t.Parallel()
+
+	nan := math.NaN()
+	testCases := []struct {
+		name     string
+		provider Validator
+		valid    bool
+	}{
+		{"NormalSlice", NormalSlice[byte]{Threshold: 2}, true},
+		{"NormalSlice zero", NormalSlice[byte]{}, true},
+		{"NormalSlice negative", NormalSlice[byte]{Threshold: -1}, false},
+		{"NormalSlice NaN", NormalSlice[byte]{Threshold: nan}, false},
+		{"NormalSlice lower", NormalSlice[byte]{LowerThreshold: -1}, false},
+		{"NormalSlice upper", NormalSlice[byte]{UpperThreshold: -1}, false},
+		{"NormalSlicePtr", NormalSlicePtr[byte]{
+			NormalSlice: NormalSlice[byte]{Threshold: -1},
+		}, false},
+		{"NormalBytesBuffer", NormalBytesBuffer{Threshold: 2}, true},
+		{"NormalBytesBuffer negative", NormalBytesBuffer{Threshold: -1}, false},
+		{"NormalBytesBuffer upper", NormalBytesBuffer{
+			UpperThreshold: nan,
+		}, false},
+		{"NormalStringsBuilder", NormalStringsBuilder{Threshold: -1}, false},
+		{"NormalMap", NormalMap[int, int]{Threshold: -1}, false},
+		{"NormalChan", NormalChan[int]{Threshold: -1}, false},
+		{"PageAlignedSlice", PageAlignedSlice{Threshold: -1}, false},
+		{"RobustNormalSlice", NewRobustNormalSlice[byte](0, -1), false},
+		{"NormalByteSlices", NewNormalByteSlices(0, -1, 500), false},
+		{"InterfaceProvider", InterfaceProvider[any]{Threshold: -1}, false},
+		{"FuncProvider", NewFuncProvider(sizeofRequest, 2, createRequest),
+			true},
+		{"FuncProvider negative", NewFuncProvider(sizeofRequest, -1,
+			createRequest), false},
+		{"FuncProvider NaN", NewFuncProvider(sizeofRequest, nan,
+			createRequest), false},
+		{"FuncProvider strategy", NewStrategyFuncProvider(sizeofRequest,
+			NormalBand{UpperThreshold: -1}, createRequest), false},
+		{"FuncProvider AcceptAll", NewStrategyFuncProvider(sizeofRequest,
+			AcceptAll{}, createRequest), true},
+		{"FuncProvider literal", FuncProvider[*request]{}, true},
+	}
+	for _, tc := range testCases {
+		err := tc.provider.Validate()
+		equal(t, tc.valid, err == nil, "%s: %v", tc.name, err)
+		equal(t, tc.valid, !errors.Is(err, ErrInvalidThreshold),
+			"%s: should wrap ErrInvalidThreshold", tc.name)
+	}
+}
+
+func TestAdaptivePoolResettingProvider(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 2,
+	}, 500)
+	buf := ap.Get()
+	buf.WriteString("stale contents")
+	ap.Put(buf)
+	equal(t, 1, sp.Len(), "should have retained the buffer")
+	st := ap.Stats()
+	equal(t, float64(len("stale contents")), st.Mean(),
+		"should measure before resetting")
+
+	got := ap.Get()
+	equal(t, buf, got, "should reuse the buffer")
+	zero(t, got.Len(), "reused buffer should be empty")
+}
+
+func TestAdaptivePoolMaxN(t *testing.T) {
+	t.Parallel()
+
+	ap, _ := newStackAdaptivePool[int](intProvider{}, 0)
+	zero(t, ap.MaxN(), "MaxN in new pool")
+	ap.SetMaxN(0.1)
+	zero(t, ap.MaxN(), "MaxN should not change if set to number < 1")
+
+	for range 4 {
+		ap.Put(1)
+	}
+	ap.SetMaxN(1.1)
+	equal(t, 1, ap.MaxN(), "maxN should be round to nearest integer")
+	st := ap.Stats()
+	equal(t, 1, st.N(), "N should have been capped to maxN")
+	equal(t, 1, st.MaxN(), "should change MaxN of the statistics")
+
+	ap.SetMaxN(0)
+	for range 4 {
+		ap.Put(1)
+	}
+	zero(t, ap.MaxN(), "maxN")
+	st = ap.Stats()
+	equal(t, 5, st.N(), "N should not be capped")
+
+	custom := NewWithStats[int](intProvider{}, &lastValueStats{maxN: 10})
+	equal(t, 10, custom.MaxN(), "MaxN of custom StatsProvider")
+	custom.SetMaxN(20)
+	equal(t, 20, custom.MaxN(), "SetMaxN of custom StatsProvider")
+}
+
+func TestNewBytePool(t *testing.T) {
+	t.Parallel()
+
+	bp := NewBytePool(512, 2, 500)
+	equal[any](t, NormalSlice[byte]{MinCap: 512, Threshold: 2}, bp.provider,
+		"NewBytePool provider")
+	st := bp.Stats()
+	equal(t, 500, st.MaxN(), "NewBytePool MaxN")
+	equal(t, 512, cap(bp.Get()), "NewBytePool should use MinCap")
+
+	bbp := NewBytesBufferPool(512, 2, 500)
+	equal[any](t, NormalBytesBuffer{MinCap: 512, Threshold: 2}, bbp.provider,
+		"NewBytesBufferPool provider")
+	st = bbp.Stats()
+	equal(t, 500, st.MaxN(), "NewBytesBufferPool MaxN")
+	equal(t, 512, bbp.Get().Cap(), "NewBytesBufferPool should use MinCap")
+}
+
+func TestNewSeeded(t *testing.T) {
+	t.Parallel()
+
+	const thresh, maxN = 2, 50
+	seed := NewStatsSeed(100, 1000, 10)
+	ap := NewSeeded[[]byte](NormalSlice[byte]{
+		Threshold: thresh,
+	}, maxN, seed)
+
+	st := ap.Stats()
+	equal(t, maxN, st.N(), "N should be capped to maxN")
+	equal(t, maxN, st.MaxN(), "MaxN should be overridden")
+	equal(t, seed.Mean(), st.Mean(), "Mean")
+
+	got := cap(ap.Get())
+	equal(t, 1000+thresh*10, got, "capacity of first created item")
+}
+
+func TestAdaptivePoolConcurrentStats(t *testing.T) {
+	t.Parallel()
+	const putters, snapshotters, iterations = 8, 8, 1000
+
+	ap := New[int](intProvider{}, 0)
+	ap.setPool(nopPool{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < putters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				ap.Put(1 + j%10)
+			}
+		}()
+	}
+
+	errCh := make(chan error, snapshotters)
+	for i := 0; i < snapshotters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				st := ap.Stats()
+				n, mean, sd := st.N(), st.Mean(), st.StdDev()
+				if math.IsNaN(sd) != (n < 2) || math.IsNaN(mean) ||
+					math.IsInf(mean, 0) || n > 0 && (mean < 1 || mean > 10) {
+					errCh <- fmt.Errorf("inconsistent snapshot: n=%v, "+
+						"mean=%v, stdDev=%v", n, mean, sd)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+	st := ap.Stats()
+	equal(t, putters*iterations, st.N(), "final N")
+}
+
+func TestAdaptivePoolPutNilPointer(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 2,
+	}, 0)
+	ap.Put(bytes.NewBuffer(make([]byte, 10)))
+	ap.Put(bytes.NewBuffer(make([]byte, 30)))
+	before := ap.Stats()
+
+	ap.Put(nil)
+	ap.PutForce(nil)
+	equal(t, before, ap.Stats(), "nil pointers should not update stats")
+	equal(t, 2, sp.Len(), "nil pointers should not be retained")
+	equal(t, 20, before.Mean(), "Mean")
+}
+
+func TestAdaptivePoolTryGet(t *testing.T) {
+	t.Parallel()
+
+	ap := New[int](intProvider{}, 0)
+	x, ok := ap.TryGet()
+	equal(t, false, ok, "should fail on an empty sync.Pool")
+	equal(t, 0, x, "should return the zero value")
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	_, ok = ap.TryGet()
+	equal(t, false, ok, "should fail on an empty pool")
+	st := ap.Stats()
+	zero(t, st.N(), "should not update stats")
+
+	ap.Put(42)
+	x, ok = ap.TryGet()
+	equal(t, true, ok, "should succeed after Put")
+	equal(t, 42, x, "should return the item put")
+	equal(t, 0, sp.Len(), "item should have been removed from the pool")
+}
+
+func TestAdaptivePoolReset(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[[]byte](NormalSlice[byte]{
+		MinCap:    8,
+		Threshold: 1,
+	}, 50)
+	for i := 0; i < 10; i++ {
+		ap.Put(make([]byte, 1<<20))
+	}
+	equal(t, 10, sp.Len(), "retained items")
+
+	ap.Reset()
+	st := ap.Stats()
+	zero(t, st.N(), "N after Reset")
+	equal(t, 50, st.MaxN(), "MaxN should be kept")
+	equal(t, StatusCold, ap.Status(), "Status after Reset")
+	_, ok := ap.TryGet()
+	equal(t, false, ok, "pooled items should be dropped")
+	equal(t, 8, cap(ap.Get()), "should create a minimally-sized item")
+
+	// the lock-free statistics are the same as the ones of a new pool, with a
+	// NaN stdDev so that a SizeAccepter accepts the first item
+	wantMean, wantStdDev := New[[]byte](NormalSlice[byte]{}, 50).FastStats()
+	mean, stdDev := ap.FastStats()
+	equal(t, wantMean, mean, "FastStats mean after Reset")
+	equal(t, true, math.IsNaN(wantStdDev) && math.IsNaN(stdDev),
+		"FastStats stdDev after Reset: %v", stdDev)
+
+	// concurrent use
+	ap = New[[]byte](NormalSlice[byte]{Threshold: 1}, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				ap.Put(append(ap.Get(), make([]byte, j)...))
+				if j%100 == 0 {
+					ap.Reset()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// lastValueStats is a StatsProvider whose Mean is the last pushed value,
+// counting the calls to Push.
+type lastValueStats struct {
+	pushes, n, maxN, last float64
+}
+
+func (s *lastValueStats) Push(v float64) {
+	s.pushes++
+	s.n++
+	if s.maxN >= 1 {
+		s.n = min(s.n, s.maxN)
+	}
+	s.last = v
+}
+
+func (s *lastValueStats) Reset()            { *s = lastValueStats{maxN: s.maxN} }
+func (s *lastValueStats) N() float64        { return s.n }
+func (s *lastValueStats) MaxN() float64     { return s.maxN }
+func (s *lastValueStats) SetMaxN(n float64) { s.maxN = n }
+func (s *lastValueStats) Mean() float64     { return s.last }
+func (s *lastValueStats) StdDev() float64   { return 0 }
+
+func TestNewWithStats(t *testing.T) {
+	t.Parallel()
+
+	custom := &lastValueStats{maxN: 3}
+	ap := NewWithStats[int](intProvider{}, custom)
+	ap.setPool(nopPool{})
+	for _, v := range []int{10, 20, 30, 40} {
+		ap.Put(v)
+	}
+	ap.PutForce(50)
+	equal(t, 5, custom.pushes, "Push should be called on every Put")
+	equal(t, 50, ap.Get(), "should create items from the custom stats")
+
+	st := ap.Stats()
+	equal(t, 3, st.N(), "N")
+	equal(t, 3, st.MaxN(), "MaxN")
+	equal(t, 50, st.Mean(), "Mean")
+	zero(t, st.StdDev(), "StdDev")
+
+	ap.Reset()
+	zero(t, custom.N(), "N after Reset")
+	equal(t, 3, custom.MaxN(), "MaxN should be kept after Reset")
+
+	var buf bytes.Buffer
+	zero(t, New[int](intProvider{}, 0).SaveState(&buf), "SaveState")
+	equal(t, true, ap.LoadState(&buf) != nil,
+		"LoadState should fail with a custom StatsProvider")
+}
+
+func TestAdaptivePoolFastStats(t *testing.T) {
+	t.Parallel()
+
+	ap, _ := newStackAdaptivePool[int](intProvider{}, 0)
+	for _, v := range []int{1 << 24, 1<<24 + 3, 1<<24 + 7} {
+		ap.Put(v)
+		st := ap.Stats()
+		mean, stdDev := ap.FastStats()
+		equal(t, float64(float32(st.Mean())), mean, "Mean")
+		wantSD := float64(float32(st.StdDev()))
+		equal(t, math.IsNaN(wantSD), math.IsNaN(stdDev), "NaN StdDev")
+		if !math.IsNaN(wantSD) {
+			equal(t, wantSD, stdDev, "StdDev")
+		}
+	}
+	mean, _ := ap.FastStats()
+	st := ap.Stats()
+	equal(t, true, mean != st.Mean(), "should have float32 precision")
+}
+
+func TestAdaptivePoolOnDrop(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[[]int](NormalSlice[int]{
+		Threshold: 1,
+	}, 0)
+	var drops []float64
+	ap.SetOnDrop(func(itemSize, mean, stdDev float64) {
+		drops = append(drops, itemSize)
+		st := ap.Stats() // should not deadlock
+		equal(t, float64(float32(st.Mean())), mean, "Mean")
+	})
+
+	// same as the ramping sizes scenario
+	ap.Put(nil)
+	for _, v := range []int{10, 10, 10, 20, 20, 20, 30, 30, 30, 50, 50, 50,
+		50, 50, 50} {
+		ap.Put(make([]int, v))
+	}
+	want := []float64{20, 20, 30, 30, 30, 50, 50, 50, 50, 50, 50}
+	equal(t, fmt.Sprint(want), fmt.Sprint(drops), "dropped sizes")
+	equal(t, 4, sp.Len(), "retained items")
+
+	drops = nil
+	ap.PutForce(make([]int, 1000))
+	zero(t, len(drops), "forced items should not be dropped")
+}
+
+func TestAdaptivePoolPrePut(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	var seen []float64
+	ap.SetPrePut(func(size float64) bool {
+		seen = append(seen, size)
+		return size != 42
+	})
+
+	ap.Put(10)
+	ap.Put(42)
+	ap.PutForce(42)
+	ap.Put(20)
+
+	equal(t, 4, len(seen), "PrePut calls")
+	equal(t, 2, sp.Len(), "vetoed items should be dropped")
+	st := ap.Stats()
+	equal(t, 2, st.N(), "vetoed items should not update stats")
+	equal(t, 15, st.Mean(), "Mean")
+
+	ap.SetPrePut(nil)
+	ap.Put(42)
+	equal(t, 3, sp.Len(), "items should be retained without PrePut")
+}
+
+func TestAdaptivePoolPutObserve(t *testing.T) {
+	t.Parallel()
+
+	ap, _ := newStackAdaptivePool[[]int](NormalSlice[int]{
+		Threshold: 1,
+	}, 0)
+	// same as the ramping sizes scenario of TestNormalMap
+	for i, c := range []struct {
+		size          int
+		accepted      bool
+		before, after float64
+	}{
+		{10, true, 0, 10},
+		{10, true, 10, 10},
+		{10, true, 10, 10},
+		{20, false, 10, 16},
+		{20, false, 16, 18},
+		{20, true, 18, 20},
+	} {
+		accepted, before, after := ap.PutObserve(make([]int, c.size))
+		equal(t, c.accepted, accepted, "[#%d] accepted", i)
+		equal(t, c.before, before, "[#%d] CreateSize before", i)
+		equal(t, c.after, after, "[#%d] CreateSize after", i)
+	}
+
+	accepted, before, after := ap.PutObserve(nil)
+	equal(t, false, accepted, "nil slice should not be accepted")
+	equal(t, 20, before, "CreateSize should not change")
+	equal(t, 20, after, "CreateSize should not change")
+
+	ints := New[int](intProvider{}, 0)
+	accepted, before, after = ints.PutObserve(10)
+	equal(t, true, accepted, "should be accepted")
+	equal(t, true, math.IsNaN(before), "should be NaN without CreateSizer")
+	equal(t, true, math.IsNaN(after), "should be NaN without CreateSizer")
+}
+
+func TestAdaptivePoolDropCached(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	for _, v := range []int{10, 20, 30} {
+		ap.Put(v)
+	}
+	equal(t, 3, sp.Len(), "retained items")
+	before := ap.Stats()
+
+	ap.DropCached()
+	after := ap.Stats()
+	equal(t, before, after, "Stats should be kept")
+	equal(t, 3, sp.Len(), "old pool should not be used")
+
+	equal(t, 20, ap.Get(), "should create a new item from the stats")
+	equal(t, 1, ap.Misses(), "Get should be a miss")
+}
+
+func TestAdaptivePoolMaxItems(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	ap.SetMaxItems(2)
+	for i := 0; i < 4; i++ {
+		ap.Put(1)
+	}
+	equal(t, 2, sp.Len(), "extra items should be dropped")
+	equal(t, 2, ap.Metrics().Drops, "Drops")
+	ap.PutForce(1)
+	equal(t, 2, sp.Len(), "PutForce should also be limited")
+
+	ap.Get()
+	ap.Put(1)
+	ap.Put(1)
+	equal(t, 2, sp.Len(), "Get should make room for one item")
+
+	// simulate sync.Pool discarding items
+	sp.Get()
+	sp.Get()
+	ap.Get()
+	ap.Put(1)
+	ap.Put(1)
+	ap.Put(1)
+	equal(t, 2, sp.Len(), "count should be reset after an empty Get")
+
+	ap.Reset()
+	sp = ap.loadPool().(*stackPool)
+	ap.Put(1)
+	ap.Put(1)
+	equal(t, 2, sp.Len(), "Reset should reset the count")
+
+	ap.SetMaxItems(0)
+	ap.Put(1)
+	equal(t, 3, sp.Len(), "items should be retained without limit")
+}
+
+func TestAdaptivePoolSeed(t *testing.T) {
+	t.Parallel()
+
+	const thresh, mean, stdDev = 2, 100, 10
+	ap, sp := newStackAdaptivePool[[]int](NormalSlice[int]{
+		Threshold: thresh,
+	}, 500)
+	ap.Seed(mean, stdDev, 50)
+	st := ap.Stats()
+	equal(t, 50, st.N(), "N")
+	equal(t, mean, st.Mean(), "Mean")
+	equal(t, stdDev, st.StdDev(), "StdDev")
+	equal(t, 500, st.MaxN(), "MaxN should be kept")
+	equal(t, int(normalCreateSize(mean, stdDev, thresh)), cap(ap.Get()),
+		"first item should be well-sized")
+	zero(t, sp.Len(), "should not create items")
+
+	ap.Seed(mean, stdDev, 1000)
+	st = ap.Stats()
+	equal(t, 500, st.N(), "N should be capped to MaxN")
+	equal(t, stdDev, st.StdDev(), "StdDev")
+
+	// the decay and the other configuration of the statistics are kept
+	decaying := new(Stats)
+	decaying.SetDecay(0.1)
+	decaying.SetWinsorize(3)
+	dp := NewWithStats[[]int](NormalSlice[int]{Threshold: thresh}, decaying)
+	dp.Seed(mean, stdDev, 50)
+	st = dp.Stats()
+	equal(t, 0.1, st.Decay(), "Decay should be kept")
+	equal(t, 3, st.winsorK, "winsorizing should be kept")
+	zero(t, st.MaxN(), "MaxN")
+	dp.Put(make([]int, mean+stdDev))
+	want := NewStatsSeed(50, mean, stdDev)
+	want.SetDecay(0.1)
+	want.SetWinsorize(3)
+	want.Push(mean + stdDev)
+	equal(t, want, dp.Stats(), "should keep computing an EWMA after Seed")
+
+	custom := &lastValueStats{maxN: 3}
+	cp := NewWithStats[int](intProvider{}, custom)
+	cp.Seed(mean, stdDev, 10)
+	equal(t, 3, custom.pushes, "pushes should be capped to MaxN")
+	equal(t, mean, cp.Get(), "should create items from the custom stats")
+	cp.Seed(mean, stdDev, 2)
+	equal(t, 2, custom.n, "custom stats should be Reset")
+	equal(t, mean+stdDev, custom.last, "last pushed value")
+}
+
+func TestAdaptivePoolState(t *testing.T) {
+	t.Parallel()
+
+	provider := NormalSlice[byte]{Threshold: 2}
+	src := New[[]byte](provider, 100)
+	for _, v := range []int{100, 120, 80, 110, 90} {
+		src.Put(make([]byte, v))
+	}
+
+	buf := new(bytes.Buffer)
+	zero(t, src.SaveState(buf), "SaveState")
+
+	dst, _ := newStackAdaptivePool[[]byte](provider, 100)
+	zero(t, dst.LoadState(buf), "LoadState")
+
+	want, got := src.Stats(), dst.Stats()
+	equal(t, want, got, "loaded stats")
+	equal(t, src.CreateSize(), float64(cap(dst.Get())),
+		"first created item after LoadState")
+
+	// MaxN of the destination pool should be kept
+	buf.Reset()
+	zero(t, src.SaveState(buf), "SaveState")
+	dst, _ = newStackAdaptivePool[[]byte](provider, 3)
+	zero(t, dst.LoadState(buf), "LoadState")
+	got = dst.Stats()
+	equal(t, 3, got.MaxN(), "MaxN")
+	equal(t, 3, got.N(), "N should be capped")
+
+	// the configuration of the destination pool is kept, and not replaced
+	// with the one of the source
+	buf.Reset()
+	zero(t, src.SaveState(buf), "SaveState")
+	decaying := new(Stats)
+	decaying.SetDecay(0.1)
+	dst = NewWithStats[[]byte](provider, decaying)
+	zero(t, dst.LoadState(buf), "LoadState")
+	got = dst.Stats()
+	equal(t, 0.1, got.Decay(), "Decay should be kept")
+	zero(t, got.MaxN(), "MaxN should be kept")
+	equal(t, want.Mean(), got.Mean(), "loaded Mean")
+	equal(t, want.N(), got.N(), "loaded N")
+
+	err := dst.LoadState(bytes.NewReader([]byte("invalid")))
+	equal(t, true, err != nil, "should fail with invalid data")
+	err = dst.LoadState(bytes.NewReader([]byte("APST\x02")))
+	equal(t, true, err != nil, "should fail with unsupported version")
+	err = dst.LoadState(iotest.ErrReader(errors.New("read error")))
+	equal(t, true, err != nil, "should fail if reading fails")
+}
+
+func TestAdaptivePoolGob(t *testing.T) {
+	t.Parallel()
+
+	provider := NormalSlice[byte]{Threshold: 2}
+	src := New[[]byte](provider, 100)
+	for _, v := range []int{100, 120, 80, 110, 90} {
+		src.Put(make([]byte, v))
+	}
+
+	buf := new(bytes.Buffer)
+	zero(t, gob.NewEncoder(buf).Encode(src), "Encode")
+	dst, _ := newStackAdaptivePool[[]byte](provider, 100)
+	zero(t, gob.NewDecoder(buf).Decode(dst), "Decode")
+
+	want, got := src.Stats(), dst.Stats()
+	equal(t, want, got, "decoded stats")
+	equal(t, src.CreateSize(), float64(cap(dst.Get())),
+		"first created item after decoding")
+
+	err := dst.GobDecode([]byte("invalid"))
+	equal(t, true, err != nil, "should fail with invalid data")
+}
+
+func TestAdaptivePoolPrecise(t *testing.T) {
+	t.Parallel()
+
+	// float32 has a 24bit mantissa, so this value can't be represented exactly
+	const size = 1<<24 + 1
+	equal(t, 1<<24, int(float32(size)), "test value should be rounded")
+
+	ap := New[int](intProvider{}, 0)
+	ap.setPool(nopPool{})
+	ap.Put(size)
+	equal(t, 1<<24, ap.Get(), "default mode should round")
+
+	ap = New[int](intProvider{}, 0)
+	ap.setPool(nopPool{})
+	ap.SetPrecise(true)
+	ap.Put(size)
+	equal(t, size, ap.Get(), "precise mode should not round")
+}
+
+// fakeMemStats returns the configured heap statistics.
+type fakeMemStats struct {
+	heapAlloc, nextGC atomic.Uint64
+}
+
+func (f *fakeMemStats) ReadMemStats(m *runtime.MemStats) {
+	m.HeapAlloc, m.NextGC = f.heapAlloc.Load(), f.nextGC.Load()
+}
+
+func TestAdaptivePoolSpikeDetection(t *testing.T) {
+	t.Parallel()
+
+	provider := NormalSlice[byte]{Threshold: 1}
+	ref, _ := newStackAdaptivePool[[]byte](provider, 0)
+	ap, _ := newStackAdaptivePool[[]byte](provider, 0)
+	ap.SetSpikeDetection(0.5, 2)
+	put := func(size int) {
+		ref.Put(make([]byte, size))
+		ap.Put(make([]byte, size))
+	}
+
+	for i := 0; i < 100; i++ {
+		put(10 + i%3)
+	}
+	equal(t, ref.CreateSize(), ap.CreateSize(), "no spike at baseline")
+
+	for i := 0; i < 5; i++ {
+		put(1000)
+		if ap.CreateSize() < 500 || ap.CreateSize() < 2*ref.CreateSize() {
+			t.Fatalf("[#%d] should size up quickly during the burst; "+
+				"got: %v, without spike detection: %v", i,
+				ap.CreateSize(), ref.CreateSize())
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		put(10)
+	}
+	equal(t, ref.CreateSize(), ap.CreateSize(),
+		"should return to baseline after the burst")
+
+	ap.SetSpikeDetection(0, 0)
+	put(1000)
+	equal(t, ref.CreateSize(), ap.CreateSize(), "disabled spike detection")
+}
+
+func TestAdaptivePoolGCAwareness(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	ms := new(fakeMemStats)
+	ap.memStats = ms
+	m := new(runtime.MemStats)
+	ms.nextGC.Store(100)
+
+	assertRetained := func(v int, expected bool) {
+		t.Helper()
+		curLen := sp.Len()
+		ap.Put(v)
+		equal(t, expected, sp.Len() > curLen, "retained item of size %d", v)
+	}
+
+	ms.heapAlloc.Store(50)
+	ap.pollMemStats(m)
+	assertRetained(10, true)
+	assertRetained(20, true) // mean=15
+
+	ms.heapAlloc.Store(95)
+	ap.pollMemStats(m)
+	assertRetained(30, false) // mean=20
+	assertRetained(10, true)  // mean=17.5
+	ap.PutForce(50)
+	equal(t, 4, sp.Len(), "PutForce should retain regardless of pressure")
+
+	ms.heapAlloc.Store(80) // in the gap, should not change state
+	ap.pollMemStats(m)
+	assertRetained(100, false)
+
+	ms.heapAlloc.Store(60)
+	ap.pollMemStats(m)
+	assertRetained(100, true)
+
+	// background polling
+	ms.heapAlloc.Store(99)
+	ap.EnableGCAwareness(time.Millisecond)
+	for !ap.pressure.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	assertRetained(1000, false)
+	ap.DisableGCAwareness()
+	assertRetained(1000, true)
+
+	// deterministic background polling with a fake clock
+	clk := newFakeClock()
+	ap.clock = clk
+	poll := func() {
+		t.Helper()
+		clk.Advance(time.Second)
+		clk.Advance(time.Second) // the previous tick was fully processed
+	}
+	ap.EnableGCAwareness(time.Second)
+	clk.Advance(time.Second / 2)
+	equal(t, false, ap.pressure.Load(), "should not poll before interval")
+	ms.heapAlloc.Store(99)
+	poll()
+	assertRetained(1000, false)
+	ms.heapAlloc.Store(10)
+	poll()
+	assertRetained(1000, true)
+	ap.DisableGCAwareness()
+	clk.Advance(time.Second) // should not block after stopping
+}
+
+func TestAdaptivePoolStartDecay(t *testing.T) {
+	t.Parallel()
+
+	ap, _ := newStackAdaptivePool[int](intProvider{}, 0)
+	clk := newFakeClock()
+	ap.clock = clk
+	for range 16 {
+		ap.Put(10)
+	}
+	// stopping waits for the received ticks to be processed
+	decay := func(ticks int) float64 {
+		t.Helper()
+		ap.StartDecay(time.Second, 0.5)
+		for range ticks {
+			clk.Advance(time.Second)
+		}
+		ap.StopDecay()
+		st := ap.Stats()
+		return st.N()
+	}
+
+	equal(t, 16, decay(0), "should not decay before interval")
+	equal(t, 8, decay(1), "should decay on each tick")
+	equal(t, 2, decay(2), "should decay on each tick")
+	equal(t, 2, decay(1), "should not decay below 2")
+	st := ap.Stats()
+	equal(t, 10, st.Mean(), "should keep the Mean")
+
+	ap.StartDecay(time.Second, 0.5)
+	zero(t, ap.Close(), "Close")
+	equal(t, true, ap.decayStop == nil, "Close should stop decaying")
+	clk.Advance(time.Second) // should not block after stopping
+
+	custom := NewWithStats[int](intProvider{}, new(lastValueStats))
+	custom.StartDecay(time.Second, 0.5)
+	equal(t, true, custom.decayStop == nil,
+		"should be a no-op without Forget")
+}
+
+func TestAdaptivePoolClose(t *testing.T) {
+	t.Parallel()
+
+	ap, _ := newStackAdaptivePool[int](intProvider{}, 500)
+	clk := newFakeClock()
+	ap.clock = clk
+	ap.EnableGCAwareness(time.Second)
+	ap.Put(1)
+	zero(t, ap.Close(), "Close")
+	equal(t, true, ap.gcStop == nil, "should stop GC awareness")
+	zero(t, ap.Close(), "second Close should be a no-op")
+	clk.Advance(time.Second) // should not block after stopping
+
+	assertPanics := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			equal[any](t, errPoolClosed, recover(), "%s should panic", name)
+		}()
+		f()
+	}
+	assertPanics("Get", func() { ap.Get() })
+	assertPanics("TryGet", func() { ap.TryGet() })
+	assertPanics("GetWait", func() { ap.GetWait(context.Background()) })
+	assertPanics("Put", func() { ap.Put(1) })
+	assertPanics("PutForce", func() { ap.PutForce(1) })
+	assertPanics("PutObserve", func() { ap.PutObserve(1) })
+
+	st := ap.Stats()
+	equal(t, 1, st.N(), "Stats should still be available")
+}
+
+func TestAdaptivePoolOnCreate(t *testing.T) {
+	t.Parallel()
+
+	type createInfo struct {
+		mean, stdDev, size float64
+	}
+	var created []createInfo
+
+	ap := New[[]int](NormalSlice[int]{
+		MinCap:    5,
+		Threshold: 2,
+	}, 0)
+	ap.setPool(nopPool{})
+	ap.SetOnCreate(func(mean, stdDev, size float64) {
+		created = append(created, createInfo{mean, stdDev, size})
+	})
+
+	var caps []int
+	for _, v := range []int{10, 20, 30, 40} {
+		caps = append(caps, cap(ap.Get()))
+		ap.Put(make([]int, v))
+	}
+	caps = append(caps, cap(ap.Get()))
+
+	equal(t, len(caps), len(created), "number of created items")
+	for i, c := range created {
+		equal(t, float64(caps[i]), c.size, "[#%d] recorded size", i)
+	}
+	equal(t, 25, created[len(created)-1].mean, "last recorded mean")
+
+	ints := New[int](intProvider{}, 0)
+	ints.setPool(nopPool{})
+	ints.SetOnCreate(func(mean, stdDev, size float64) {
+		equal(t, true, math.IsNaN(size),
+			"size should be NaN without CreateSizer")
+	})
+	ints.Get()
+}
+
+func TestAggregateStats(t *testing.T) {
+	t.Parallel()
+
+	provider := NormalSlice[byte]{Threshold: 1}
+	values := [][]int{
+		{10, 10, 20, 20},
+		{},
+		{5},
+		{100, 200, 300},
+	}
+
+	single := New[[]byte](provider, 0)
+	pools := make([]*AdaptivePool[[]byte], len(values))
+	for i, vs := range values {
+		pools[i] = New[[]byte](provider, float64(100*(i+1)))
+		for _, v := range vs {
+			pools[i].Put(make([]byte, v))
+			single.Put(make([]byte, v))
+		}
+	}
+
+	want, got := single.Stats(), AggregateStats(pools...)
+	equal(t, want.N(), got.N(), "N")
+	equal(t, 1000, got.MaxN(), "MaxN should be the sum of all MaxN")
+	equal(t, roundOneDecimal(want.Mean()), roundOneDecimal(got.Mean()), "Mean")
+	equal(t, roundOneDecimal(want.StdDev()), roundOneDecimal(got.StdDev()),
+		"StdDev")
+
+	pools = append(pools, New[[]byte](provider, 0))
+	got = AggregateStats(pools...)
+	zero(t, got.MaxN(), "MaxN with an unbounded pool")
+
+	got = AggregateStats[[]byte]()
+	zero(t, got.N(), "N without pools")
+	zero(t, got.MaxN(), "MaxN without pools")
+}
+
+func TestAdaptivePoolCreateSize(t *testing.T) {
+	t.Parallel()
+
+	slices, _ := newStackAdaptivePool[[]int](NormalSlice[int]{
+		MinCap:    4,
+		Threshold: 2,
+	}, 0)
+	equal(t, 4, slices.CreateSize(), "CreateSize of empty pool")
+	equal(t, 4, cap(slices.Get()), "cap of item from empty pool")
+	for _, v := range []int{10, 20, 30, 40} {
+		slices.Put(make([]int, v))
+		slices.setPool(new(stackPool)) // force Get to create a new item
+		want := slices.CreateSize()
+		equal(t, want, float64(cap(slices.Get())), "cap of created item")
+	}
+
+	buffers, _ := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 1,
+	}, 0)
+	for _, v := range []int{10, 20, 30, 40} {
+		buffers.Put(bytes.NewBuffer(make([]byte, v)))
+		buffers.setPool(new(stackPool))
+		want := buffers.CreateSize()
+		equal(t, want, float64(buffers.Get().Cap()), "Cap of created buffer")
+	}
+
+	custom := New[int](intProvider{}, 0)
+	equal(t, true, math.IsNaN(custom.CreateSize()),
+		"CreateSize should be NaN if not supported by the provider")
+}
+
+// sizeAcceptBuffer is a NormalBytesBuffer that also implements SizeAccepter,
+// counting the calls to each method.
+type sizeAcceptBuffer struct {
+	NormalBytesBuffer
+	sizeofCalls, sizeAndAcceptCalls *int
+}
+
+func (p sizeAcceptBuffer) Sizeof(v *bytes.Buffer) float64 {
+	*p.sizeofCalls++
+	return p.NormalBytesBuffer.Sizeof(v)
+}
+
+func (p sizeAcceptBuffer) SizeAndAccept(mean, stdDev float64,
+	v *bytes.Buffer) (float64, bool) {
+	*p.sizeAndAcceptCalls++
+	size := p.NormalBytesBuffer.Sizeof(v)
+	return size, p.Accept(mean, stdDev, size)
+}
+
+func TestAdaptivePoolSizeAccepter(t *testing.T) {
+	t.Parallel()
+
+	var sizeofCalls, sizeAndAcceptCalls int
+	provider := sizeAcceptBuffer{
+		NormalBytesBuffer:  NormalBytesBuffer{Threshold: 1},
+		sizeofCalls:        &sizeofCalls,
+		sizeAndAcceptCalls: &sizeAndAcceptCalls,
+	}
+
+	// both paths should produce the same decisions given the same stats
+	testCases := []struct {
+		mean, stdDev float64
+		size         int
+	}{
+		{0, math.NaN(), 0},
+		{10, math.NaN(), 20},
+		{10, 3, 10},
+		{10, 3, 13},
+		{10, 3, 14},
+		{10, 3, 6},
+	}
+	for i, tc := range testCases {
+		v := bytes.NewBuffer(make([]byte, tc.size))
+		size := provider.NormalBytesBuffer.Sizeof(v)
+		accept := provider.Accept(tc.mean, tc.stdDev, size)
+		gotSize, gotAccept := provider.SizeAndAccept(tc.mean, tc.stdDev, v)
+		equal(t, size, gotSize, "[#%d] size", i)
+		equal(t, accept, gotAccept, "[#%d] accept", i)
+	}
+	sizeAndAcceptCalls = 0
+
+	ap, sp := newStackAdaptivePool[*bytes.Buffer](provider, 0)
+	ap.Put(nil)
+	ap.Put(bytes.NewBuffer(make([]byte, 10)))
+	ap.Put(bytes.NewBuffer(make([]byte, 10)))
+	ap.Put(bytes.NewBuffer(make([]byte, 10)))
+	ap.Put(bytes.NewBuffer(make([]byte, 50)))
+	equal(t, 5, sizeAndAcceptCalls, "SizeAndAccept should be used")
+	zero(t, sizeofCalls, "Sizeof should not be used")
+	equal(t, 3, sp.Len(), "retained items")
+
+	st := ap.Stats()
+	equal(t, 4, st.N(), "N")
+	equal(t, 20, st.Mean(), "Mean")
+
+	// both paths of the pool retain the same items if their sizes are
+	// clearly inside or outside of the band
+	twoCalls, _ := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 1,
+	}, 0)
+	oneCall, _ := newStackAdaptivePool[*bytes.Buffer](provider, 0)
+	sizes := []int{10, 10, 12, 8, 10, 100, 11, 9, 10, 1000, 12, 2, 10}
+	for i, size := range sizes {
+		want, _, _ := twoCalls.PutObserve(bytes.NewBuffer(make([]byte, size)))
+		got, _, _ := oneCall.PutObserve(bytes.NewBuffer(make([]byte, size)))
+		equal(t, want, got, "[#%d] retained item of size %d", i, size)
+	}
+	equal(t, twoCalls.Metrics(), oneCall.Metrics(), "Metrics of both paths")
+
+	// near the bounds of the band, the decisions may differ, since
+	// SizeAndAccept receives the statistics before pushing the size
+	provider.NormalBytesBuffer.Threshold = 2
+	twoCalls, _ = newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 2,
+	}, 0)
+	oneCall, _ = newStackAdaptivePool[*bytes.Buffer](provider, 0)
+	for i, size := range []int{10, 10, 10} {
+		want, _, _ := twoCalls.PutObserve(bytes.NewBuffer(make([]byte, size)))
+		got, _, _ := oneCall.PutObserve(bytes.NewBuffer(make([]byte, size)))
+		equal(t, true, want && got, "[#%d] retained item of size %d", i,
+			size)
+	}
+	twoCallsAccepted, _, _ := twoCalls.PutObserve(bytes.NewBuffer(
+		make([]byte, 11)))
+	oneCallAccepted, _, _ := oneCall.PutObserve(bytes.NewBuffer(
+		make([]byte, 11)))
+	equal(t, true, twoCallsAccepted, "Accept should use the statistics "+
+		"after the push, with a positive stdDev")
+	equal(t, false, oneCallAccepted, "SizeAndAccept should use the "+
+		"statistics before the push, with a zero stdDev")
+	equal(t, twoCalls.Stats(), oneCall.Stats(), "both paths should push the "+
+		"same sizes")
+}
+
+// intProvider is a PoolItemProvider for ints, where each int is its own size,
+// and which accepts all items.
+type intProvider struct{}
+
+func (intProvider) Sizeof(v int) float64 { return float64(v) }
+
+func (intProvider) Create(mean, stdDev float64) int { return int(mean) }
+
+func (intProvider) Accept(mean, stdDev, itemSize float64) bool { return true }
+
 type adaptivePoolAsserter[T any] struct {
 	t        *testing.T
 	pool     *testPool
@@ -125,8 +1420,8 @@ func newAdaptivePoolAsserter[T any](
 ) adaptivePoolAsserter[T] {
 	pool := new(testPool)
 	ap := New[T](p, 0)
-	ap.pool = pool
-	pool.New = ap.new
+	ap.setPool(pool)
+	pool.New = func() any { return ap.new() }
 	return adaptivePoolAsserter[T]{
 		t:        t,
 		pool:     pool,
@@ -150,9 +1445,20 @@ func (a adaptivePoolAsserter[T]) assertGet(expectedSize float64) {
 }
 
 func (a adaptivePoolAsserter[T]) assertPut(v T, expectDropped bool) {
+	a.t.Helper()
+	a.assertPutFunc(a.ap.Put, v, expectDropped)
+}
+
+func (a adaptivePoolAsserter[T]) assertPutForce(v T, expectDropped bool) {
+	a.t.Helper()
+	a.assertPutFunc(a.ap.PutForce, v, expectDropped)
+}
+
+func (a adaptivePoolAsserter[T]) assertPutFunc(put func(T), v T,
+	expectDropped bool) {
 	a.t.Helper()
 	curCount := a.pool.putCount
-	a.ap.Put(v)
+	put(v)
 	wasDropped := curCount == a.pool.putCount
 	if wasDropped != expectDropped {
 		var expectedStr string
@@ -192,6 +1498,254 @@ type testPool struct {
 func (p *testPool) Get() any  { return p.New() }
 func (p *testPool) Put(x any) { p.putCount++ }
 
+// stackPool is a LIFO pool that retains all the values passed to it, with
+// deterministic behaviour. It is safe for concurrent use.
+type stackPool struct {
+	mu    sync.Mutex
+	items []any
+}
+
+func (p *stackPool) Get() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.items) == 0 {
+		return nil
+	}
+	x := p.items[len(p.items)-1]
+	p.items = p.items[:len(p.items)-1]
+	return x
+}
+
+func (p *stackPool) Put(x any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.items = append(p.items, x)
+}
+
+func (p *stackPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.items)
+}
+
+func newStackAdaptivePool[T any](p PoolItemProvider[T],
+	maxN float64) (*AdaptivePool[T], *stackPool) {
+	sp := new(stackPool)
+	ap := New(p, maxN)
+	ap.setPool(sp)
+	ap.newPool = func() pool { return new(stackPool) }
+	return ap, sp
+}
+
+func TestAdaptivePoolGetWait(t *testing.T) {
+	t.Parallel()
+
+	t.Run("item available", func(t *testing.T) {
+		t.Parallel()
+		ap, _ := newStackAdaptivePool[[]byte](NormalSlice[byte]{}, 0)
+		ap.Put(make([]byte, 10))
+
+		got, err := ap.GetWait(context.Background())
+		zero(t, err, "GetWait error")
+		equal(t, 10, len(got), "should have returned the pooled item")
+	})
+
+	t.Run("concurrent put unblocks", func(t *testing.T) {
+		t.Parallel()
+		ap, _ := newStackAdaptivePool[[]byte](NormalSlice[byte]{}, 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		type result struct {
+			item []byte
+			err  error
+		}
+		resCh := make(chan result)
+		go func() {
+			item, err := ap.GetWait(ctx)
+			resCh <- result{item, err}
+		}()
+
+		for ap.waiters.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		ap.Put(make([]byte, 10))
+
+		res := <-resCh
+		zero(t, res.err, "GetWait error")
+		equal(t, 10, len(res.item), "should have returned the pooled item")
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		t.Parallel()
+		ap, _ := newStackAdaptivePool[[]byte](NormalSlice[byte]{}, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		item, err := ap.GetWait(ctx)
+		equal(t, true, errors.Is(err, context.Canceled),
+			"should return the context error")
+		zero(t, item, "should return the zero value on error")
+	})
+
+	t.Run("context done with allocation", func(t *testing.T) {
+		t.Parallel()
+		ap, _ := newStackAdaptivePool[[]byte](NormalSlice[byte]{
+			MinCap: 8,
+		}, 0)
+		ap.SetWaitAllocate(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Millisecond)
+		defer cancel()
+
+		item, err := ap.GetWait(ctx)
+		zero(t, err, "GetWait error")
+		equal(t, 8, cap(item), "should have created a new item")
+	})
+}
+
+func TestNormalSliceCapBased(t *testing.T) {
+	t.Parallel()
+	v := func(n int) []int {
+		return make([]int, 0, n)
+	}
+
+	ap, sp := newStackAdaptivePool[[]int](NormalSlice[int]{
+		Threshold: 1,
+		CapBased:  true,
+	}, 0)
+	ap.Put(nil) // should be a nop
+	equal(t, 0, sp.Len(), "nil slice should not be retained")
+
+	// same sizes as in TestNormalMap, but with zero length
+	for i, c := range []struct {
+		size       int
+		createSize float64
+	}{
+		{10, 10}, {10, 10}, {10, 10}, {20, 16}, {20, 18}, {20, 20},
+	} {
+		ap.Put(v(c.size))
+		equal(t, c.createSize, ap.CreateSize(), "[#%d] CreateSize", i)
+	}
+	equal(t, 4, sp.Len(), "retained slices")
+	st := ap.Stats()
+	equal(t, 15, st.Mean(), "Mean")
+
+	lenBased, _ := newStackAdaptivePool[[]int](NormalSlice[int]{
+		Threshold: 1,
+	}, 0)
+	lenBased.Put(v(10))
+	lenBased.Put(v(20))
+	st = lenBased.Stats()
+	zero(t, st.Mean(), "length-based sizing should learn nothing")
+}
+
+func TestNormalBytesBufferCapBased(t *testing.T) {
+	t.Parallel()
+	v := func(n int) *bytes.Buffer {
+		b := bytes.NewBuffer(make([]byte, n))
+		b.Reset()
+		return b
+	}
+
+	ap, sp := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 1,
+		CapBased:  true,
+	}, 0)
+	ap.Put(nil) // should be a nop
+	ap.Put(new(bytes.Buffer))
+	equal(t, 0, sp.Len(), "buffers without capacity should not be retained")
+
+	// same sizes as in TestNormalSliceCapBased
+	for i, c := range []struct {
+		size       int
+		createSize float64
+	}{
+		{10, 10}, {10, 10}, {10, 10}, {20, 16}, {20, 18}, {20, 20},
+	} {
+		ap.Put(v(c.size))
+		equal(t, c.createSize, ap.CreateSize(), "[#%d] CreateSize", i)
+	}
+	equal(t, 4, sp.Len(), "retained buffers")
+	st := ap.Stats()
+	equal(t, 15, st.Mean(), "Mean")
+	equal(t, 20, ap.Get().Cap(), "Cap of retained buffer")
+
+	lenBased, _ := newStackAdaptivePool[*bytes.Buffer](NormalBytesBuffer{
+		Threshold: 1,
+	}, 0)
+	lenBased.Put(v(10))
+	lenBased.Put(v(20))
+	st = lenBased.Stats()
+	zero(t, st.Mean(), "length-based sizing should learn nothing")
+}
+
+func TestNormalMinCap(t *testing.T) {
+	t.Parallel()
+	const minCap = 64
+
+	slicePool := New[[]byte](NormalSlice[byte]{
+		MinCap:    minCap,
+		Threshold: 2,
+	}, 0)
+	slicePool.setPool(nopPool{})
+	bufferPool := New[*bytes.Buffer](NormalBytesBuffer{
+		MinCap:    minCap,
+		Threshold: 2,
+	}, 0)
+	bufferPool.setPool(nopPool{})
+
+	equal(t, minCap, cap(slicePool.Get()), "slice capacity without stats")
+	equal(t, minCap, bufferPool.Get().Cap(), "buffer capacity without stats")
+	for _, size := range []int{1, 2, 3, 2, 1} {
+		slicePool.Put(make([]byte, size))
+		bufferPool.Put(bytes.NewBuffer(make([]byte, size)))
+		equal(t, minCap, cap(slicePool.Get()),
+			"slice capacity after size %d", size)
+		equal(t, minCap, bufferPool.Get().Cap(),
+			"buffer capacity after size %d", size)
+	}
+}
+
+func TestNormalMaxCap(t *testing.T) {
+	t.Parallel()
+	const maxCap = 100
+
+	slicePool := New[[]byte](NormalSlice[byte]{
+		MaxCap:    maxCap,
+		Threshold: 2,
+	}, 0)
+	slicePool.setPool(nopPool{})
+	bufferPool := New[*bytes.Buffer](NormalBytesBuffer{
+		MaxCap:    maxCap,
+		Threshold: 2,
+	}, 0)
+	bufferPool.setPool(nopPool{})
+
+	for _, size := range []int{10, 20, 30, 10_000, 20, 10} {
+		slicePool.Put(make([]byte, size))
+		bufferPool.Put(bytes.NewBuffer(make([]byte, size)))
+		if c := cap(slicePool.Get()); c > maxCap {
+			t.Fatalf("slice capacity %d exceeds MaxCap after size %d", c,
+				size)
+		}
+		if c := bufferPool.Get().Cap(); c > maxCap {
+			t.Fatalf("buffer capacity %d exceeds MaxCap after size %d", c,
+				size)
+		}
+	}
+	equal(t, maxCap, slicePool.CreateSize(), "slice CreateSize")
+	equal(t, maxCap, bufferPool.CreateSize(), "buffer CreateSize")
+
+	p := NormalSlice[byte]{MinCap: 8, MaxCap: 4}
+	equal(t, 4, p.CreateSize(0, math.NaN()), "MaxCap takes precedence")
+	p.MaxCap = 0
+	equal(t, 1000, p.CreateSize(1000, 0), "zero MaxCap means unlimited")
+}
+
 func TestNormalCreateSize(t *testing.T) {
 	t.Parallel()
 
@@ -215,6 +1769,124 @@ func TestNormalCreateSize(t *testing.T) {
 	}
 }
 
+func TestNormalAcceptBand(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		n, mean, stdDev, lower, upper, itemSize float64
+		expected                                bool
+	}{
+		// same as in TestNormalAccept, with a symmetric band
+		{0, 0, math.NaN(), 0, 0, 0, true},
+		{1, 0, math.NaN(), 0, 0, 0, true},
+		{2, 10, 3, 1, 1, 0, false},
+		{2, 10, 3, 1, 1, 10, true},
+		{2, 10, 3, 1, 1, 7, true},
+		{2, 10, 3, 1, 1, 13, true},
+		{2, 10, 3, 1, 1, 6.99, false},
+		{2, 10, 3, 1, 1, 13.01, false},
+
+		// asymmetric bands
+		{1, 10, math.NaN(), 0.5, 2, 100, true},
+		{2, 10, 3, 0.5, 2, 8.5, true},
+		{2, 10, 3, 0.5, 2, 8.49, false},
+		{2, 10, 3, 0.5, 2, 16, true},
+		{2, 10, 3, 0.5, 2, 16.01, false},
+		{2, 10, 3, 2, 0.5, 4, true},
+		{2, 10, 3, 2, 0.5, 11.51, false},
+	}
+
+	for i, tc := range testCases {
+		sd := tc.stdDev
+		if tc.n < 2 {
+			sd = math.NaN()
+		}
+		got := normalAcceptBand(tc.mean, sd, tc.lower, tc.upper, tc.itemSize)
+		if got != tc.expected {
+			t.Errorf("testCase[%v] unexpected %v", i, got)
+		}
+	}
+
+	slice := NormalSlice[byte]{Threshold: 1, UpperThreshold: 2}
+	equal(t, true, slice.Accept(10, 3, 16), "UpperThreshold")
+	equal(t, false, slice.Accept(10, 3, 6.99), "fall back to Threshold")
+	buffer := NormalBytesBuffer{Threshold: 1, LowerThreshold: 2}
+	equal(t, true, buffer.Accept(10, 3, 4), "LowerThreshold")
+	equal(t, false, buffer.Accept(10, 3, 13.01), "fall back to Threshold")
+}
+
+func TestNormalAcceptLarger(t *testing.T) {
+	t.Parallel()
+
+	inf := math.Inf(1)
+	equal(t, true, normalAcceptBand(10, 0, 1, inf, 1e9),
+		"unbounded with zero stdDev")
+	equal(t, false, normalAcceptBand(10, 0, 1, inf, 9),
+		"lower bound with zero stdDev")
+
+	slicePool, slices := newStackAdaptivePool[[]byte](NormalSlice[byte]{
+		Threshold:    1,
+		AcceptLarger: true,
+	}, 0)
+	bufferPool, buffers := newStackAdaptivePool[*bytes.Buffer](
+		NormalBytesBuffer{
+			Threshold:    1,
+			AcceptLarger: true,
+		}, 0)
+	for _, size := range []int{100, 90, 110, 100, 100} {
+		slicePool.Put(make([]byte, size))
+		bufferPool.Put(bytes.NewBuffer(make([]byte, size)))
+	}
+	equal(t, 5, slices.Len(), "should retain the usual slices")
+	equal(t, 5, buffers.Len(), "should retain the usual buffers")
+
+	slicePool.Put(make([]byte, 1, 1e3))
+	bufferPool.Put(bytes.NewBuffer(make([]byte, 1, 1e3)))
+	equal(t, 5, slices.Len(), "should drop tiny slices")
+	equal(t, 5, buffers.Len(), "should drop tiny buffers")
+
+	slicePool.Put(make([]byte, 1e6))
+	bufferPool.Put(bytes.NewBuffer(make([]byte, 1e6)))
+	equal(t, 6, slices.Len(), "should retain large outlier slices")
+	equal(t, 6, buffers.Len(), "should retain large outlier buffers")
+
+	st := slicePool.Stats()
+	want := NormalSlice[byte]{Threshold: 1}.CreateSize(st.Mean(), st.StdDev())
+	equal(t, want, slicePool.CreateSize(), "should not change CreateSize")
+}
+
+func TestNormalNegativeSizes(t *testing.T) {
+	t.Parallel()
+
+	equal(t, 0, normalCreateSize(-5, math.NaN(), 1), "negative mean")
+	equal(t, 0, normalCreateSize(-5, 1, 2), "negative create size")
+
+	// negative sizes are dropped by Put
+	p := NewFuncProvider(
+		func([]byte) float64 { return -5 },
+		1,
+		NormalSlice[byte]{}.Create,
+	)
+	ap := New[[]byte](p, 0)
+	ap.Put(make([]byte, 10))
+	st := ap.Stats()
+	zero(t, st.N(), "negative sizes should not be pushed")
+
+	// but statistics may still come from negative values
+	seed := NewStatsSeed(10, -5, 1)
+	slicePool := NewSeeded[[]byte](NormalSlice[byte]{Threshold: 1}, 0, seed)
+	zero(t, cap(slicePool.Get()), "negative create size should be zero")
+	slicePool = NewSeeded[[]byte](NormalSlice[byte]{
+		MinCap:    8,
+		Threshold: 1,
+	}, 0, seed)
+	equal(t, 8, cap(slicePool.Get()), "MinCap")
+	mapPool := NewSeeded[map[int]int](NormalMap[int, int]{}, 0, seed)
+	equal(t, true, mapPool.Get() != nil, "should create a map")
+	bufferPool := NewSeeded[*bytes.Buffer](NormalBytesBuffer{}, 0, seed)
+	zero(t, bufferPool.Get().Cap(), "negative create size should be zero")
+}
+
 func TestNormalAccept(t *testing.T) {
 	t.Parallel()
 
@@ -244,6 +1916,22 @@ func TestNormalAccept(t *testing.T) {
 	}
 }
 
+func TestAdaptivePoolStatsGeneration(t *testing.T) {
+	t.Parallel()
+	const n = 10
+	ap, _ := newStackAdaptivePool[int](intProvider{}, 500)
+	zero(t, ap.StatsGeneration(), "new pool")
+	for i := range n {
+		ap.Put(i)
+	}
+	equal(t, n, ap.StatsGeneration(), "should count each Put")
+
+	ap.Reset()
+	equal(t, n, ap.StatsGeneration(), "should not be reset")
+	ap.Put(1)
+	equal(t, n+1, ap.StatsGeneration(), "should count Put after Reset")
+}
+
 func TestEncoding(t *testing.T) {
 	t.Parallel()
 	testCases := []uint64{
@@ -256,3 +1944,32 @@ func TestEncoding(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodingNaN(t *testing.T) {
+	t.Parallel()
+	nans := []uint32{nan32Bits, 0x7f800001, 0xffc00000, 0x7fbfffff}
+	for i, bits := range nans {
+		nan := canonicalNaN32(math.Float32frombits(bits))
+		lo, hi := decodeBits(encodeBits(42, nan))
+		if lo != 42 || math.Float32bits(hi) != nan32Bits ||
+			!math.IsNaN(float32To64(hi)) {
+			t.Errorf("[#%d] NaN %#x: got lo=%v, hi=%#x", i, bits, lo,
+				math.Float32bits(hi))
+		}
+	}
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 500)
+	var gotMean, gotStdDev float64
+	ap.SetOnCreate(func(mean, stdDev, size float64) {
+		gotMean, gotStdDev = mean, stdDev
+	})
+	ap.Put(10) // n=1 ; mean=10 ; stdDev=NaN
+	sp.Get()   // force creating a new item
+
+	equal(t, 10, ap.Get(), "should create an item with the mean")
+	equal(t, 10, gotMean, "Create mean")
+	equal(t, true, math.IsNaN(gotStdDev), "Create stdDev should be NaN")
+	mean, stdDev := ap.FastStats()
+	equal(t, 10, mean, "FastStats mean")
+	equal(t, true, math.IsNaN(stdDev), "FastStats stdDev should be NaN")
+}
@@ -2,10 +2,12 @@ package adaptivepool
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"unicode/utf8"
 )
 
 // ReaderBufferer buffers data from [io.Reader]s and [io.ReadCloser]s into
@@ -13,9 +15,21 @@ import (
 // back into an [AdaptivePool] for reuse.
 type ReaderBufferer struct {
 	bufPool AdaptivePool[[]byte]
-	rdPool  sync.Pool
+	// release is p.put, stored once so that filling a BufferedReader doesn't
+	// allocate a new method value each time
+	release func([]byte)
+
+	retries   int
+	retryable func(error) bool
+	maxSize   int
+
+	sizes sizeHistory
 }
 
+// ErrMaxSizeExceeded is returned when buffering more data than the maximum set
+// with [ReaderBufferer.SetMaxSize].
+var ErrMaxSizeExceeded = errors.New("maximum buffer size exceeded")
+
 // NewReaderBufferer returns a new ReaderBufferer. The `minCap` and `thresh`
 // arguments will be the values of the internal [NormalSlice.MinCap] and
 // [NormalSlice.Threshold], respectively. Example:
@@ -27,7 +41,7 @@ func NewReaderBufferer(minCap int, thresh, maxN float64) *ReaderBufferer {
 
 func (p *ReaderBufferer) init(minCap int, thresh,
 	maxN float64) *ReaderBufferer {
-	p.rdPool.New = newBytesReader
+	p.release = p.put
 	p.bufPool.init(NormalSlice[byte]{
 		MinCap:    minCap,
 		Threshold: thresh,
@@ -35,8 +49,25 @@ func (p *ReaderBufferer) init(minCap int, thresh,
 	return p
 }
 
-func newBytesReader() any {
-	return bytes.NewReader(nil)
+// SetRetry makes the ReaderBufferer retry reading up to `retries` times after
+// read errors for which `retryable` returns true, which is useful for readers
+// with transient errors. The data read before each error is preserved, and
+// reading continues into the same buffer. It may not be changed concurrently
+// with any other method. A nil `retryable` disables retrying.
+func (p *ReaderBufferer) SetRetry(retries int, retryable func(error) bool) {
+	if retryable == nil {
+		retries = 0
+	}
+	p.retries, p.retryable = retries, retryable
+}
+
+// SetMaxSize sets the maximum number of bytes that will be buffered. Buffering
+// stops after that, and an error wrapping ErrMaxSizeExceeded is returned, which
+// protects against unbounded streams. Using a value less than or equal to zero
+// means no limit, which is the default. It may not be changed concurrently
+// with any other method.
+func (p *ReaderBufferer) SetMaxSize(maxSize int) {
+	p.maxSize = maxSize
 }
 
 // Stats returns the statistics from the internal AdaptivePool.
@@ -44,83 +75,402 @@ func (p *ReaderBufferer) Stats() Stats {
 	return p.bufPool.Stats()
 }
 
+// SizeHistogram returns a histogram of the sizes of the data buffered during
+// the last minute, the same sizes used to update the statistics. Sizes are
+// counted in buckets whose bounds are consecutive powers of two, and only the
+// non-empty buckets are returned, sorted by size. The sizes are kept in a ring
+// of time slots of 10 seconds, so the oldest sizes are forgotten a slot at a
+// time. Sizes are only recorded after the first call to this method, so that
+// the ReaderBufferers that don't use it don't pay for it, which means that the
+// first call returns no buckets.
+func (p *ReaderBufferer) SizeHistogram() []Bucket {
+	return p.sizes.buckets(p.bufPool.clock.Now())
+}
+
+// Pool returns the internal AdaptivePool of the buffers, which allows tuning
+// it at runtime, like with [AdaptivePool.SetMaxN], and inspecting its metrics.
+// The ReaderBufferer owns the pool, so callers should not Get or Put items
+// directly, which could interfere with the statistics, nor Close it, which
+// would make buffering panic.
+func (p *ReaderBufferer) Pool() *AdaptivePool[[]byte] {
+	return &p.bufPool
+}
+
 // Reader buffers the contents of the given io.Reader in a BufferedReader.
 func (p *ReaderBufferer) Reader(r io.Reader) (*BufferedReader, error) {
 	return p.buf(r, nil)
 }
 
+// ReaderWithSize is like Reader, but `sizeHint` is the expected size of the
+// contents of `r`, like the Content-Length of an HTTP body, so that the buffer
+// is grown to hold them before reading, if needed, avoiding intermediate
+// reallocations. The statistics are updated with the actual size, as usual.
+// Since the hint may come from an untrusted source, like an HTTP header, it is
+// capped to the maximum size set with SetMaxSize, or to 1MiB if there is none.
+// Larger contents are still buffered, growing the buffer while reading.
+func (p *ReaderBufferer) ReaderWithSize(r io.Reader,
+	sizeHint int) (*BufferedReader, error) {
+	return p.bufSize(r, nil, sizeHint)
+}
+
+// ReaderN is like Reader, but it only buffers the first `n` bytes of `r`, or
+// less if it ends before that, and it returns a reader for the rest of the
+// data, which is `r` itself since no more than `n` bytes are read from it. This
+// is useful to buffer a header and keep streaming the remainder elsewhere.
+// The maximum size set with SetMaxSize also applies to the `n` bytes. In case
+// of error, the data read up to that point is discarded, and the position of
+// `r` is undefined.
+func (p *ReaderBufferer) ReaderN(r io.Reader,
+	n int64) (*BufferedReader, io.Reader, error) {
+	br, err := p.buf(io.LimitReader(r, n), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return br, r, nil
+}
+
+// WrapSeeker is like Reader, but if `rs` also implements io.ReaderAt, like an
+// *os.File or a *bytes.Reader, and its unread data is larger than the buffers
+// created by the internal AdaptivePool (see [AdaptivePool.CreateSize]), then
+// the data is not buffered. Instead, the returned BufferedReader reads directly
+// from `rs`, starting at its current position, so that large inputs are not
+// copied. Smaller inputs are buffered the same as with ReaderWithSize.
+//
+// Unlike with buffered data, `rs` remains owned by the caller, who must not
+// modify nor close it until the BufferedReader is closed. Closing the
+// BufferedReader doesn't close `rs`, doesn't put anything back into the pool
+// and doesn't update the statistics. Its methods Bytes, IntoBuffer and Drain
+// allocate a new slice and read the data into it, and Append is not supported.
+// The maximum size set with SetMaxSize only applies to buffered data.
+func (p *ReaderBufferer) WrapSeeker(rs io.ReadSeeker) (*BufferedReader, error) {
+	off, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("ReaderBufferer.WrapSeeker: %w", err)
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = rs.Seek(off, io.SeekStart)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReaderBufferer.WrapSeeker: %w", err)
+	}
+	size := max(end-off, 0)
+
+	ra, ok := rs.(io.ReaderAt)
+	if !ok || float64(size) <= p.bufPool.CreateSize() {
+		return p.bufSize(rs, nil, int(size))
+	}
+	return &BufferedReader{
+		reader: &seekReader{
+			SectionReader: io.NewSectionReader(ra, off, size),
+			prevRune:      -1,
+		},
+	}, nil
+}
+
+// copyBufferSize is the size of the buffer used by CopyTo when the internal
+// AdaptivePool creates empty buffers, which is the same used by io.Copy. It's
+// also the default limit of the sizes recorded by CopyTo.
+const copyBufferSize = 32 << 10
+
+// CopyTo copies the contents of `src` to `dst`, using a buffer from the
+// internal AdaptivePool, and returns the number of bytes copied. Unlike with
+// Reader, the contents are not buffered all at once, but in chunks of the size
+// of the buffer, which is read into and then written in a loop, the same as
+// with io.Copy, until `src` returns io.EOF. The buffer is then put back into
+// the pool, and the statistics are updated with the number of bytes copied,
+// also deciding with it whether to retain the buffer. That number is capped to
+// the maximum size set with SetMaxSize, or to 32KiB if there is none, so that
+// the pool learns to create buffers that hold small contents whole, but a
+// large copy doesn't make later calls to Get allocate a buffer of its size.
+// Read errors are retried as configured with SetRetry, and returned as a
+// *BufferError. The maximum size doesn't limit the bytes copied, since nothing
+// is buffered.
+func (p *ReaderBufferer) CopyTo(dst io.Writer, src io.Reader) (int64, error) {
+	buf := p.bufPool.Get()
+	if cap(buf) == 0 {
+		buf = make([]byte, min(copyBufferSize, p.copySizeLimit()))
+	}
+	buf = buf[:cap(buf)]
+	var n int64
+	defer p.putCopyBuffer(buf, &n)
+
+	for retries := 0; ; {
+		nr, readErr := src.Read(buf)
+		if nr > 0 {
+			nw, writeErr := dst.Write(buf[:nr])
+			if nw < 0 || nw > nr {
+				nw, writeErr = 0, errors.New("invalid Write result")
+			}
+			n += int64(nw)
+			if writeErr == nil && nw != nr {
+				writeErr = io.ErrShortWrite
+			}
+			if writeErr != nil {
+				return n, fmt.Errorf("ReaderBufferer.CopyTo: %w", writeErr)
+			}
+		}
+		switch {
+		case readErr == io.EOF:
+			return n, nil
+		case readErr == nil:
+		case retries < p.retries && p.retryable(readErr):
+			retries++
+		default:
+			return n, &BufferError{
+				Read:      readErr,
+				BytesRead: n,
+			}
+		}
+	}
+}
+
 // ReadCloser buffers the contents of the given io.ReadCloser in a
 // BufferedReader. It always calls Close, and it fails if it returns an error.
 func (p *ReaderBufferer) ReadCloser(rc io.ReadCloser) (*BufferedReader, error) {
 	return p.buf(rc, rc)
 }
 
+// Decode buffers the decompressed contents of the given io.Reader in a
+// BufferedReader, using a pooled Decoder of the given format from `d`.
+func (p *ReaderBufferer) Decode(d *Decoders, format string,
+	r io.Reader) (*BufferedReader, error) {
+	dec, err := d.Get(format, r)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Put(format, dec)
+	return p.buf(dec, nil)
+}
+
+// ReadCloserDecode is like Decode, but for an io.ReadCloser, which is always
+// closed, the same as with ReadCloser.
+func (p *ReaderBufferer) ReadCloserDecode(d *Decoders, format string,
+	rc io.ReadCloser) (*BufferedReader, error) {
+	dec, err := d.Get(format, rc)
+	if err != nil {
+		return nil, errors.Join(err, rc.Close())
+	}
+	defer d.Put(format, dec)
+	return p.buf(dec, rc)
+}
+
+// ReadCloserGzip is like ReadCloserDecode, using FormatGzip and a Decoders
+// shared by the package, so that the statistics reflect the decompressed
+// sizes.
+func (p *ReaderBufferer) ReadCloserGzip(
+	rc io.ReadCloser) (*BufferedReader, error) {
+	return p.ReadCloserDecode(defaultDecoders(), FormatGzip, rc)
+}
+
+// defaultDecoders is used by ReadCloserGzip.
+var defaultDecoders = sync.OnceValue(NewDecoders)
+
+// ReaderContext is like Reader, but it aborts with an error wrapping the
+// context error if `ctx` is done. The context is checked before each call to
+// the Read method of `r`, so a blocked call will not be interrupted.
+func (p *ReaderBufferer) ReaderContext(ctx context.Context,
+	r io.Reader) (*BufferedReader, error) {
+	return p.buf(ctxReader{ctx, r}, nil)
+}
+
+// ReadCloserContext is like ReadCloser, but it aborts with an error wrapping
+// the context error if `ctx` is done, the same as ReaderContext. It always
+// calls Close.
+func (p *ReaderBufferer) ReadCloserContext(ctx context.Context,
+	rc io.ReadCloser) (*BufferedReader, error) {
+	return p.buf(ctxReader{ctx, rc}, rc)
+}
+
+// ctxReader is an io.Reader that fails if its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// BufferError is returned by the methods of [ReaderBufferer] when reading or
+// closing fails. Both errors can be matched with [errors.Is] and [errors.As].
+type BufferError struct {
+	Read      error // error reading, or nil
+	Close     error // error closing, or nil
+	BytesRead int64 // number of bytes read before failing
+}
+
+// Error is part of the implementation of the error interface.
+func (e *BufferError) Error() string {
+	switch {
+	case e.Close == nil:
+		return fmt.Sprintf("read io.Reader: %v; bytes read: %v", e.Read,
+			e.BytesRead)
+	case e.Read == nil:
+		return fmt.Sprintf("close io.ReadCloser: %v; bytes read: %v",
+			e.Close, e.BytesRead)
+	}
+	return fmt.Sprintf("buffer io.ReadCloser: read error: %v; close error: "+
+		"%v; bytes read: %v", e.Read, e.Close, e.BytesRead)
+}
+
+// Unwrap returns the non-nil errors among Read and Close.
+func (e *BufferError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	for _, err := range []error{e.Read, e.Close} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ResetReader is like Reader, but it buffers the contents of `r` into `br`,
+// which must be closed, instead of allocating a new BufferedReader. This is
+// useful to buffer many readers in a loop, closing `br` after each one. If
+// buffering fails, then `br` is left closed.
+func (p *ReaderBufferer) ResetReader(br *BufferedReader, r io.Reader) error {
+	if br.reader != nil {
+		return errors.New("ReaderBufferer.ResetReader: BufferedReader is open")
+	}
+	return p.fill(br, r, nil, 0)
+}
+
 func (p *ReaderBufferer) buf(r io.Reader,
 	c io.Closer) (*BufferedReader, error) {
-	buf := p.bufPool.Get()
+	return p.bufSize(r, c, 0)
+}
+
+func (p *ReaderBufferer) bufSize(r io.Reader, c io.Closer,
+	sizeHint int) (*BufferedReader, error) {
+	br := new(BufferedReader)
+	if err := p.fill(br, r, c, sizeHint); err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// maxSizeHint is the maximum size hint used to grow a buffer before reading if
+// there is no maximum size, so that a bogus hint can't force a large
+// allocation.
+const maxSizeHint = 1 << 20
+
+// fill buffers the contents of `r` into `br`, which must be closed. If `c` is
+// not nil, then it is always closed. If `sizeHint` is positive, then the buffer
+// is grown to hold that many bytes before reading, up to the maximum size or
+// maxSizeHint.
+func (p *ReaderBufferer) fill(br *BufferedReader, r io.Reader,
+	c io.Closer, sizeHint int) error {
+	// pooled buffers keep their length so that it's measured on Put
+	buf := p.bufPool.Get()[:0]
 	bytesBuf := bytes.NewBuffer(buf)
+	if p.maxSize > 0 {
+		// read one more byte to detect exceeding the limit
+		r = io.LimitReader(r, int64(p.maxSize)+1)
+		sizeHint = min(sizeHint, p.maxSize+1)
+	} else {
+		sizeHint = min(sizeHint, maxSizeHint)
+	}
+	if sizeHint > 0 {
+		// bytes.Buffer.ReadFrom grows the buffer unless it has room for at
+		// least bytes.MinRead more bytes, even if only EOF is left
+		bytesBuf.Grow(sizeHint + bytes.MinRead)
+	}
 	n, readErr := bytesBuf.ReadFrom(r)
-	if readErr != nil && c == nil {
-		p.put(buf)
-		return nil, fmt.Errorf("read io.Reader: %w; bytes read: %v", readErr, n)
+	for i := 0; readErr != nil && i < p.retries && p.retryable(readErr); i++ {
+		var m int64
+		m, readErr = bytesBuf.ReadFrom(r)
+		n += m
 	}
 	buf = bytesBuf.Bytes()
-
+	if readErr == nil && p.maxSize > 0 && len(buf) > p.maxSize {
+		readErr = ErrMaxSizeExceeded
+	}
 	var closeErr error
 	if c != nil {
 		closeErr = c.Close()
-		if readErr == nil && closeErr != nil {
-			p.put(buf)
-			return nil, fmt.Errorf("close io.ReadCloser: %w; bytes read: %v",
-				closeErr, n)
-		}
 	}
-
 	if readErr != nil || closeErr != nil {
 		p.put(buf)
-		return nil, fmt.Errorf("buffer io.ReadCloser: read error: %w; close"+
-			" error: %w; bytes read: %v", readErr, closeErr, n)
+		return &BufferError{
+			Read:      readErr,
+			Close:     closeErr,
+			BytesRead: n,
+		}
 	}
 
-	rd := p.rdPool.Get().(*bytes.Reader)
-	rd.Reset(buf)
-
-	return &BufferedReader{
-		reader:  rd,
-		buf:     buf,
-		release: p.release,
-	}, nil
-}
-
-func (p *ReaderBufferer) release(buf []byte, rd *bytes.Reader) {
-	rd.Reset(nil)
-	p.rdPool.Put(rd)
-	p.put(buf)
+	br.rd.Reset(buf)
+	br.reader = &br.rd
+	br.buf = buf
+	br.release = p.release
+	return nil
 }
 
 func (p *ReaderBufferer) put(buf []byte) {
 	if cap(buf) > 0 {
+		p.sizes.observe(p.bufPool.clock, len(buf))
 		clear(buf[:cap(buf)])
-		p.bufPool.Put(buf[:0])
+		p.bufPool.Put(buf)
+	}
+}
+
+// putCopyBuffer is like put for the buffer used by CopyTo, but the statistics
+// are updated with the `n` bytes copied, up to copySizeLimit.
+func (p *ReaderBufferer) putCopyBuffer(buf []byte, n *int64) {
+	size := int(min(*n, int64(p.copySizeLimit())))
+	p.sizes.observe(p.bufPool.clock, size)
+	clear(buf)
+	p.bufPool.putSize(buf, float64(size))
+}
+
+// copySizeLimit returns the maximum size recorded by CopyTo.
+func (p *ReaderBufferer) copySizeLimit() int {
+	if p.maxSize > 0 {
+		return p.maxSize
 	}
+	return copyBufferSize
 }
 
-// NOTE: we explicitly do not want to offer io.ReaderAt in BufferedReader
-// because, as per its docs, "Clients of ReadAt can execute parallel ReadAt
-// calls on the same input source". This means that we should add a sync.RWMutex
-// to protect the underlying implementation and make it more heavyweight in
-// order to guard the parallel ReadAt operations from potential Close
-// operations. Clients can still use the Seek method and then Read as a
-// sequential workaround.
+// NOTE: as per the docs of io.ReaderAt, "Clients of ReadAt can execute parallel
+// ReadAt calls on the same input source". Guarding them from potential Close
+// operations would require adding a sync.RWMutex, making BufferedReader more
+// heavyweight. Instead, ReadAt is offered with the documented restriction that
+// it must not be called concurrently with any other method, which is enough
+// for read-only random access use cases. Clients can still use the Seek method
+// and then Read as a sequential workaround.
 
 // BufferedReader holds a read-only buffer of the contents extracted from an
 // [io.Reader] or [io.ReadCloser]. Its `Close` method releases internal buffers
 // for reuse, and after that it will be empty. It is not safe for concurrent
-// use.
+// use. Building with the adaptivepool_debug tag makes concurrent calls to Read,
+// Seek and Close panic, which helps finding misuse in tests.
 type BufferedReader struct {
-	reader  *bytes.Reader
+	// guard panics on concurrent calls to Read, Seek and Close when building
+	// with the adaptivepool_debug tag. It's the first field since it may have
+	// zero size
+	guard useGuard
+
+	reader  bufReader // nil if closed
 	buf     []byte
-	release func([]byte, *bytes.Reader)
+	release func([]byte)
+
+	// rd is reused by every fill of the BufferedReader, so that
+	// [ReaderBufferer.ResetReader] doesn't allocate a new *bytes.Reader
+	rd bytes.Reader
+}
+
+// bufReader is implemented by *bytes.Reader for buffered data, and by
+// *seekReader for data read directly from the source by WrapSeeker.
+type bufReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.ByteScanner
+	io.RuneScanner
+	io.WriterTo
+	Len() int
 }
 
 // Bytes returns the internal buffered []byte, transferring their ownership to
@@ -129,12 +479,76 @@ type BufferedReader struct {
 // had been called. Subsequent calls to this method return nil, the same as if
 // `Close` had been called before.
 func (bb *BufferedReader) Bytes() []byte {
-	if bb.reader != nil {
-		bb.release(nil, bb.reader)
+	switch rd := bb.reader.(type) {
+	case *bytes.Reader:
 		buf := bb.buf
 		*bb = BufferedReader{}
 		return buf
+	case *seekReader:
+		buf := rd.readFrom(0)
+		*bb = BufferedReader{}
+		return buf
+	}
+	return nil
+}
+
+// IntoBuffer returns a *bytes.Buffer whose contents are the unread bytes,
+// without copying them, transferring the ownership of the internal buffer to
+// the caller the same as Bytes. The spare capacity of the internal buffer is
+// available to the returned buffer for writing. After this, the
+// BufferedReader will behave as if `Close` had been called, and subsequent
+// calls to this method return an empty buffer.
+func (bb *BufferedReader) IntoBuffer() *bytes.Buffer {
+	switch rd := bb.reader.(type) {
+	case *bytes.Reader:
+		off := len(bb.buf) - rd.Len()
+		return bytes.NewBuffer(bb.Bytes()[off:])
+	case *seekReader:
+		buf := rd.readFrom(rd.pos())
+		*bb = BufferedReader{}
+		return bytes.NewBuffer(buf)
+	}
+	return new(bytes.Buffer)
+}
+
+// Drain returns a copy of the unread bytes, and then releases the internal
+// buffer for reuse, the same as Close. Unlike Bytes, the data before the
+// current read position is not returned, and the internal buffer is put back
+// into the pool. It returns nil if the BufferedReader is closed.
+func (bb *BufferedReader) Drain() []byte {
+	var tail []byte
+	switch rd := bb.reader.(type) {
+	case *bytes.Reader:
+		off := len(bb.buf) - rd.Len()
+		tail = append([]byte{}, bb.buf[off:]...)
+	case *seekReader:
+		tail = rd.readFrom(rd.pos())
+	default:
+		return nil
+	}
+	bb.Close()
+	return tail
+}
+
+// Append appends `p` to the buffered data, using the spare capacity of the
+// internal buffer if possible, and growing it otherwise. The read position is
+// kept, so the appended data will be read after the currently unread data, if
+// any. A previous call to ReadRune can no longer be undone with UnreadRune. If
+// the buffer is grown, then `Close` puts the grown buffer back for reuse, so
+// that the growth is reflected in the statistics. It fails if the
+// BufferedReader is closed, or if its data is not buffered.
+func (bb *BufferedReader) Append(p []byte) error {
+	rd, ok := bb.reader.(*bytes.Reader)
+	if !ok {
+		if bb.reader == nil {
+			return errors.New("BufferedReader.Append: resource closed")
+		}
+		return errors.New("BufferedReader.Append: data is not buffered")
 	}
+	off, _ := rd.Seek(0, io.SeekCurrent)
+	bb.buf = append(bb.buf, p...)
+	rd.Reset(bb.buf)
+	_, _ = rd.Seek(off, io.SeekStart)
 	return nil
 }
 
@@ -148,6 +562,8 @@ func (bb *BufferedReader) Len() int {
 
 // Read is part of the implementation of the io.Reader interface.
 func (bb *BufferedReader) Read(p []byte) (int, error) {
+	bb.guard.enter("BufferedReader")
+	defer bb.guard.exit()
 	if bb.reader != nil {
 		return bb.reader.Read(p)
 	}
@@ -158,8 +574,12 @@ func (bb *BufferedReader) Read(p []byte) (int, error) {
 // releases the internal buffer for reuse. After this, the *BufferedReader will
 // be empty. This method is idempotent and always returns a nil error.
 func (bb *BufferedReader) Close() error {
+	bb.guard.enter("BufferedReader")
+	defer bb.guard.exit()
 	if bb.reader != nil {
-		bb.release(bb.buf, bb.reader)
+		if _, ok := bb.reader.(*bytes.Reader); ok {
+			bb.release(bb.buf)
+		}
 		*bb = BufferedReader{}
 	}
 	return nil
@@ -167,6 +587,8 @@ func (bb *BufferedReader) Close() error {
 
 // Seek is part of the implementation of the io.Seeker interface.
 func (bb *BufferedReader) Seek(offset int64, whence int) (int64, error) {
+	bb.guard.enter("BufferedReader")
+	defer bb.guard.exit()
 	if bb.reader != nil {
 		return bb.reader.Seek(offset, whence)
 	}
@@ -183,6 +605,20 @@ func (bb *BufferedReader) Seek(offset int64, whence int) (int64, error) {
 	return 0, nil
 }
 
+// ReadAt is part of the implementation of the io.ReaderAt interface. Parallel
+// calls to ReadAt are safe, but they must not be concurrent with calls to any
+// other method, in particular Close, Bytes and Append. It doesn't affect the
+// position used by Read.
+func (bb *BufferedReader) ReadAt(p []byte, off int64) (int, error) {
+	if bb.reader != nil {
+		return bb.reader.ReadAt(p, off)
+	}
+	if off < 0 {
+		return 0, errors.New("BufferedReader.ReadAt: negative offset")
+	}
+	return 0, io.EOF
+}
+
 // ReadByte is part of the implementation of the io.ByteReader interface.
 func (bb *BufferedReader) ReadByte() (byte, error) {
 	if bb.reader != nil {
@@ -215,6 +651,37 @@ func (bb *BufferedReader) UnreadRune() error {
 	return errors.New("BufferedReader.UnreadRune: resource closed")
 }
 
+// ReadBytes reads until the first occurrence of `delim`, returning a copy of
+// the data up to and including the delimiter, like [bufio.Reader.ReadBytes].
+// If the delimiter is not found, it returns the data up to the end and io.EOF,
+// so that a closed BufferedReader returns nil and io.EOF. The data is read
+// directly from the internal buffer, so no additional buffering is needed.
+func (bb *BufferedReader) ReadBytes(delim byte) ([]byte, error) {
+	var line []byte
+	switch rd := bb.reader.(type) {
+	case *bytes.Reader:
+		off := len(bb.buf) - rd.Len()
+		line = bb.buf[off:]
+		if i := bytes.IndexByte(line, delim); i >= 0 {
+			line = line[:i+1]
+		}
+		_, _ = rd.Seek(int64(len(line)), io.SeekCurrent)
+		line = bytes.Clone(line)
+	case *seekReader:
+		line = rd.readBytes(delim)
+	}
+	if len(line) == 0 || line[len(line)-1] != delim {
+		return line, io.EOF
+	}
+	return line, nil
+}
+
+// ReadString is like ReadBytes, but it returns a string.
+func (bb *BufferedReader) ReadString(delim byte) (string, error) {
+	line, err := bb.ReadBytes(delim)
+	return string(line), err
+}
+
 // WriteTo is part of the implementation of the io.WriterTo interface.
 func (bb *BufferedReader) WriteTo(w io.Writer) (n int64, err error) {
 	if bb.reader != nil {
@@ -222,3 +689,146 @@ func (bb *BufferedReader) WriteTo(w io.Writer) (n int64, err error) {
 	}
 	return 0, nil
 }
+
+// seekReader provides the same methods as bytes.Reader for the data of a
+// BufferedReader that is read directly from its source.
+type seekReader struct {
+	*io.SectionReader
+	prevRune int64 // offset of the previous rune read, or -1
+}
+
+func (r *seekReader) pos() int64 {
+	off, _ := r.SectionReader.Seek(0, io.SeekCurrent)
+	return off
+}
+
+// readFrom returns a new slice with the data from `off` to the end. In case of
+// read errors, the data read up to that point is returned.
+func (r *seekReader) readFrom(off int64) []byte {
+	buf := make([]byte, max(r.Size()-off, 0))
+	n, _ := r.ReadAt(buf, off)
+	return buf[:n]
+}
+
+func (r *seekReader) Len() int {
+	return int(max(r.Size()-r.pos(), 0))
+}
+
+func (r *seekReader) Read(p []byte) (int, error) {
+	r.prevRune = -1
+	return r.SectionReader.Read(p)
+}
+
+func (r *seekReader) Seek(offset int64, whence int) (int64, error) {
+	r.prevRune = -1
+	return r.SectionReader.Seek(offset, whence)
+}
+
+func (r *seekReader) ReadByte() (byte, error) {
+	r.prevRune = -1
+	var b [1]byte
+	if _, err := io.ReadFull(r.SectionReader, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *seekReader) UnreadByte() error {
+	r.prevRune = -1
+	if r.pos() <= 0 {
+		return errors.New("BufferedReader.UnreadByte: at beginning of data")
+	}
+	_, err := r.SectionReader.Seek(-1, io.SeekCurrent)
+	return err
+}
+
+func (r *seekReader) ReadRune() (ch rune, size int, err error) {
+	r.prevRune = -1
+	off := r.pos()
+	var b [utf8.UTFMax]byte
+	n, err := r.ReadAt(b[:], off)
+	if n == 0 {
+		return 0, 0, err
+	}
+	ch, size = rune(b[0]), 1
+	if ch >= utf8.RuneSelf {
+		ch, size = utf8.DecodeRune(b[:n])
+	}
+	if _, err := r.SectionReader.Seek(int64(size), io.SeekCurrent); err != nil {
+		return 0, 0, err
+	}
+	r.prevRune = off
+	return ch, size, nil
+}
+
+func (r *seekReader) UnreadRune() error {
+	if r.prevRune < 0 {
+		return errors.New("BufferedReader.UnreadRune: previous operation " +
+			"was not a successful ReadRune")
+	}
+	_, err := r.SectionReader.Seek(r.prevRune, io.SeekStart)
+	r.prevRune = -1
+	return err
+}
+
+// readBytes reads until the first occurrence of `delim` or the end of the
+// data, in chunks. In case of read errors, the data read up to that point is
+// returned.
+func (r *seekReader) readBytes(delim byte) []byte {
+	r.prevRune = -1
+	var line []byte
+	var chunk [512]byte
+	off := r.pos()
+	for {
+		n, _ := r.ReadAt(chunk[:], off+int64(len(line)))
+		if i := bytes.IndexByte(chunk[:n], delim); i >= 0 {
+			line = append(line, chunk[:i+1]...)
+			break
+		}
+		line = append(line, chunk[:n]...)
+		if n < len(chunk) {
+			break
+		}
+	}
+	_, _ = r.SectionReader.Seek(int64(len(line)), io.SeekCurrent)
+	return line
+}
+
+func (r *seekReader) WriteTo(w io.Writer) (int64, error) {
+	r.prevRune = -1
+	return io.Copy(w, r.SectionReader)
+}
+
+// RewindableReader wraps a [BufferedReader] so that part of its data can be
+// consumed, e.g. by a middleware parsing HTTP headers, and then the full data
+// can still be handed downstream from the beginning with `Body`. Since the data
+// is already fully in memory, this is always possible without copying. It is
+// not safe for concurrent use.
+type RewindableReader struct {
+	br *BufferedReader
+}
+
+// NewRewindableReader returns a RewindableReader wrapping the given
+// BufferedReader, which should no longer be used directly.
+func NewRewindableReader(br *BufferedReader) *RewindableReader {
+	return &RewindableReader{br: br}
+}
+
+// Read is part of the implementation of the io.Reader interface.
+func (rr *RewindableReader) Read(p []byte) (int, error) {
+	return rr.br.Read(p)
+}
+
+// Body returns the wrapped BufferedReader positioned at the start of the data,
+// regardless of how much of it was previously read. Closing either the returned
+// value or the RewindableReader releases the internal buffer only once.
+func (rr *RewindableReader) Body() *BufferedReader {
+	_, _ = rr.br.Seek(0, io.SeekStart)
+	return rr.br
+}
+
+// Close is part of the implementation of the io.Closer interface. It closes the
+// wrapped BufferedReader.
+func (rr *RewindableReader) Close() error {
+	return rr.br.Close()
+}
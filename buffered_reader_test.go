@@ -1,11 +1,16 @@
 package adaptivepool
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"testing/iotest"
 )
@@ -19,6 +24,7 @@ const testData = `痛苦
 
 var _ interface { // assert interfaces from standard library
 	io.ReadSeekCloser
+	io.ReaderAt
 	io.ByteScanner
 	io.RuneScanner
 	io.WriterTo
@@ -112,6 +118,564 @@ func TestReaderBufferer(t *testing.T) {
 	})
 }
 
+func TestReaderBuffererMeasuresLength(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(512, 2, 500)
+
+	for _, data := range []string{testData, testData[:10]} {
+		br, err := brr.Reader(bytes.NewReader([]byte(data)))
+		zero(t, err, "Reader error")
+		zero(t, br.Close(), "Close error")
+	}
+	st := brr.Stats()
+	equal(t, 2, st.N(), "buffers should have been put back into the pool")
+	equal(t, float64(len(testData)+10)/2, st.Mean(),
+		"should measure the length of the buffered data")
+
+	br, err := brr.Reader(bytes.NewReader([]byte("x")))
+	zero(t, err, "Reader error with a reused buffer")
+	equal(t, "x", string(br.Bytes()), "reused buffer should be emptied")
+}
+
+func TestBufferError(t *testing.T) {
+	t.Parallel()
+	errRead := errors.New("read failed")
+	errClose := errors.New("close failed")
+	const prefix = "abc"
+	brr := NewReaderBufferer(512, 2, 500)
+
+	for i, tc := range []struct {
+		readErr, closeErr error
+		closer            bool
+		bytesRead         int64
+	}{
+		{readErr: errRead, bytesRead: 3},
+		{readErr: errRead, closer: true, bytesRead: 3},
+		{closeErr: errClose, closer: true, bytesRead: 3},
+		{readErr: errRead, closeErr: errClose, closer: true, bytesRead: 3},
+	} {
+		var r io.Reader = strings.NewReader(prefix)
+		if tc.readErr != nil {
+			r = io.MultiReader(r, iotest.ErrReader(tc.readErr))
+		}
+		var err error
+		if tc.closer {
+			_, err = brr.ReadCloser(readCloser{
+				Reader: r,
+				Closer: closerFunc(func() error { return tc.closeErr }),
+			})
+		} else {
+			_, err = brr.Reader(r)
+		}
+
+		var bufErr *BufferError
+		equal(t, true, errors.As(err, &bufErr), "[#%d] should be a "+
+			"*BufferError; got: %v", i, err)
+		equal(t, tc.bytesRead, bufErr.BytesRead, "[#%d] BytesRead", i)
+		equal[error](t, tc.readErr, bufErr.Read, "[#%d] Read", i)
+		equal[error](t, tc.closeErr, bufErr.Close, "[#%d] Close", i)
+		if tc.readErr != nil {
+			equal(t, true, errors.Is(err, tc.readErr), "[#%d] errors.Is "+
+				"read error", i)
+		}
+		if tc.closeErr != nil {
+			equal(t, true, errors.Is(err, tc.closeErr), "[#%d] errors.Is "+
+				"close error", i)
+		}
+		equal(t, false, strings.Contains(err.Error(), "nil"),
+			"[#%d] unexpected error message: %v", i, err)
+	}
+}
+
+func TestReaderBuffererResetReader(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(512, 2, 500)
+
+	br, err := brr.Reader(bytes.NewReader([]byte(testData)))
+	zero(t, err, "Reader")
+	err = brr.ResetReader(br, bytes.NewReader(nil))
+	equal(t, true, err != nil, "should fail with an open BufferedReader")
+	zero(t, iotest.TestReader(br, []byte(testData)),
+		"failed ResetReader should not modify the BufferedReader")
+	zero(t, br.Close(), "Close")
+
+	const payload = "second payload"
+	zero(t, brr.ResetReader(br, strings.NewReader(payload)), "ResetReader")
+	zero(t, iotest.TestReader(br, []byte(payload)),
+		"iotest.TestReader error on reset *BufferedReader")
+	zero(t, br.Close(), "Close")
+
+	st := brr.Stats()
+	equal(t, 2, st.N(), "both buffers should have been put back")
+
+	err = brr.ResetReader(br, iotest.ErrReader(io.ErrUnexpectedEOF))
+	equal(t, true, errors.Is(err, io.ErrUnexpectedEOF),
+		"should have failed reading")
+	zero(t, br.Len(), "should be left closed on error")
+	_, err = br.Read(make([]byte, 1))
+	equal(t, io.EOF, err, "should be left closed on error")
+}
+
+// countingReader counts the calls to Read.
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+func TestReaderBuffererResetReaderAllocs(t *testing.T) {
+	// not parallel, since allocations are counted for the whole process
+	brr := NewReaderBufferer(512, 2, 500)
+	brr.bufPool.setPool(new(stackPool)) // sync.Pool may drop items on GC
+	br := new(BufferedReader)
+	r := strings.NewReader(testData)
+
+	// putting a []byte into the pool allocates to convert it to any, so only
+	// ResetReader is measured, after the first call allocated the buffer
+	zero(t, brr.ResetReader(br, r), "first ResetReader")
+	zero(t, br.Close(), "first Close")
+	var before, after runtime.MemStats
+	var mallocs uint64
+	for i := 0; i < 100; i++ {
+		r.Reset(testData)
+		runtime.ReadMemStats(&before)
+		err := brr.ResetReader(br, r)
+		runtime.ReadMemStats(&after)
+		zero(t, err, "[#%d] ResetReader", i)
+		mallocs += after.Mallocs - before.Mallocs
+		zero(t, iotest.TestReader(br, []byte(testData)),
+			"[#%d] iotest.TestReader error on reset *BufferedReader", i)
+		zero(t, br.Close(), "[#%d] Close", i)
+	}
+	zero(t, mallocs, "ResetReader should not allocate")
+}
+
+func TestReaderBuffererReaderWithSize(t *testing.T) {
+	t.Parallel()
+	const size = 10_000
+	data := strings.Repeat("x", size)
+	brr := NewReaderBufferer(0, 2, 500)
+
+	r := &countingReader{Reader: strings.NewReader(data)}
+	br, err := brr.ReaderWithSize(r, size)
+	zero(t, err, "ReaderWithSize")
+	equal(t, 2, r.reads, "should read the data and then EOF")
+	equal(t, true, cap(br.buf) >= size, "capacity should hold the data")
+	zero(t, iotest.TestReader(br, []byte(data)), "iotest.TestReader")
+	br.Close()
+	st := brr.Stats()
+	equal(t, size, st.Mean(), "should observe the actual size")
+
+	// without the hint, the buffer is grown while reading
+	brr = NewReaderBufferer(0, 2, 500)
+	r = &countingReader{Reader: strings.NewReader(data)}
+	br, err = brr.Reader(r)
+	zero(t, err, "Reader")
+	equal(t, true, r.reads > 2, "should read in several steps")
+	br.Close()
+
+	// the hint is only an optimization
+	brr = NewReaderBufferer(0, 2, 500)
+	br, err = brr.ReaderWithSize(strings.NewReader(data), 10)
+	zero(t, err, "ReaderWithSize with a small hint")
+	equal(t, size, br.Len(), "Len with a small hint")
+	br.Close()
+
+	// a huge hint doesn't allocate a huge buffer
+	brr = NewReaderBufferer(0, 2, 500)
+	br, err = brr.ReaderWithSize(strings.NewReader(data), 1<<30)
+	zero(t, err, "ReaderWithSize with a huge hint")
+	equal(t, size, br.Len(), "Len with a huge hint")
+	equal(t, true, cap(br.buf) <= 2*(maxSizeHint+bytes.MinRead),
+		"the hint should be capped; capacity: %v", cap(br.buf))
+	br.Close()
+	large := strings.Repeat("x", 2*maxSizeHint)
+	br, err = brr.ReaderWithSize(strings.NewReader(large), len(large))
+	zero(t, err, "ReaderWithSize with contents larger than the capped hint")
+	equal(t, len(large), br.Len(), "Len with contents larger than the "+
+		"capped hint")
+	br.Close()
+
+	brr.SetMaxSize(100)
+	_, err = brr.ReaderWithSize(strings.NewReader(data), size)
+	equal(t, true, errors.Is(err, ErrMaxSizeExceeded),
+		"should fail exceeding the maximum size")
+}
+
+func TestReaderBuffererPool(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(8, 2, 500)
+	pool := brr.Pool()
+	equal(t, 500, pool.MaxN(), "MaxN of the pool")
+
+	pool.SetMaxN(2)
+	for range 3 {
+		br, err := brr.Reader(strings.NewReader(testData))
+		zero(t, err, "Reader")
+		br.Close()
+	}
+	st := brr.Stats()
+	equal(t, 2, st.N(), "should respect the new MaxN")
+	equal(t, 3, pool.Gets(), "should count the buffers obtained")
+}
+
+func TestReaderBuffererReaderN(t *testing.T) {
+	t.Parallel()
+	const n = 10
+	brr := NewReaderBufferer(8, 2, 500)
+
+	br, rest, err := brr.ReaderN(strings.NewReader(testData), n)
+	zero(t, err, "ReaderN")
+	equal(t, testData[:n], string(br.Drain()), "buffered data")
+	b, err := io.ReadAll(rest)
+	zero(t, err, "read remainder")
+	equal(t, testData[n:], string(b), "remainder")
+	st := brr.Stats()
+	equal(t, n, st.Mean(), "should only measure the buffered data")
+
+	br, rest, err = brr.ReaderN(strings.NewReader(testData), 1000)
+	zero(t, err, "ReaderN beyond the end")
+	equal(t, testData, string(br.Drain()), "all the data")
+	b, err = io.ReadAll(rest)
+	zero(t, err, "read empty remainder")
+	zero(t, len(b), "empty remainder")
+
+	readErr := errors.New("read error")
+	brr = NewReaderBufferer(8, 2, 500)
+	br, rest, err = brr.ReaderN(io.MultiReader(strings.NewReader(testData),
+		iotest.ErrReader(readErr)), 1000)
+	equal(t, true, errors.Is(err, readErr), "should fail on read errors")
+	zero(t, br, "BufferedReader on error")
+	zero(t, rest, "remainder on error")
+	st = brr.Stats()
+	equal(t, 1, st.N(), "should release the buffer on error")
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestReaderBuffererCopyTo(t *testing.T) {
+	t.Parallel()
+	const minCap = 16
+	brr := NewReaderBufferer(minCap, 2, 500)
+	sp := new(stackPool)
+	brr.bufPool.setPool(sp)
+
+	copyTo := func(name string) (reads int) {
+		t.Helper()
+		src := &countingReader{Reader: strings.NewReader(testData)}
+		dst := new(bytes.Buffer)
+		n, err := brr.CopyTo(dst, src)
+		zero(t, err, "%s: CopyTo error", name)
+		equal(t, int64(len(testData)), n, "%s: bytes copied", name)
+		equal(t, testData, dst.String(), "%s: copied data", name)
+		equal(t, 1, sp.Len(), "%s: buffer should be retained", name)
+		return src.reads
+	}
+
+	reads := copyTo("first")
+	equal(t, true, reads > len(testData)/minCap, "should copy in chunks of "+
+		"the buffer size instead of buffering all the data; reads: %v", reads)
+	st := brr.Stats()
+	equal(t, 1, st.N(), "N")
+	equal(t, float64(len(testData)), st.Mean(),
+		"should measure the bytes copied")
+
+	copyTo("second")
+	m := brr.Pool().Metrics()
+	equal(t, 2, m.Gets, "Gets")
+	equal(t, 1, m.Misses, "should reuse the buffer")
+
+	// the sizes recorded for large copies are bounded
+	large := strings.Repeat(testData, (1<<20)/len(testData)+1)
+	for _, maxSize := range []int{0, 1024, 1 << 16} {
+		rb := NewReaderBufferer(minCap, 2, 500)
+		rb.SetMaxSize(maxSize)
+		for i := 0; i < 3; i++ {
+			n, err := rb.CopyTo(io.Discard, strings.NewReader(large))
+			zero(t, err, "[maxSize=%d, #%d] large CopyTo error", maxSize, i)
+			equal(t, int64(len(large)), n, "[maxSize=%d, #%d] large bytes "+
+				"copied", maxSize, i)
+		}
+		limit := float64(copyBufferSize)
+		if maxSize > 0 {
+			limit = float64(maxSize)
+		}
+		st := rb.Stats()
+		equal(t, limit, st.Mean(), "[maxSize=%d] should record at most the "+
+			"limit", maxSize)
+		equal(t, limit, rb.Pool().CreateSize(), "[maxSize=%d] CreateSize",
+			maxSize)
+	}
+
+	readErr := errors.New("read error")
+	_, err := brr.CopyTo(io.Discard, iotest.ErrReader(readErr))
+	var bufErr *BufferError
+	equal(t, true, errors.As(err, &bufErr), "read errors should be a "+
+		"*BufferError: %v", err)
+	equal[error](t, readErr, bufErr.Read, "BufferError.Read")
+
+	writeErr := errors.New("write error")
+	n, err := brr.CopyTo(writerFunc(func(p []byte) (int, error) {
+		return 1, writeErr
+	}), strings.NewReader(testData))
+	equal(t, true, errors.Is(err, writeErr), "write error: %v", err)
+	equal(t, 1, n, "bytes copied before the write error")
+
+	var chunk int
+	n, err = brr.CopyTo(writerFunc(func(p []byte) (int, error) {
+		chunk = len(p)
+		return len(p) - 1, nil
+	}), strings.NewReader(testData))
+	equal(t, true, errors.Is(err, io.ErrShortWrite), "short write: %v", err)
+	equal(t, int64(chunk-1), n, "bytes copied before the short write")
+
+	brr.SetRetry(1, func(err error) bool { return errors.Is(err, readErr) })
+	dst := new(bytes.Buffer)
+	n, err = brr.CopyTo(dst, &flakyReader{
+		Reader:   strings.NewReader(testData),
+		Err:      readErr,
+		Every:    10,
+		Failures: 1,
+	})
+	zero(t, err, "should succeed after one retry")
+	equal(t, int64(len(testData)), n, "bytes copied with a retry")
+	equal(t, testData, dst.String(), "copied data with a retry")
+}
+
+func TestReaderBuffererWrapSeeker(t *testing.T) {
+	t.Parallel()
+	const prefix = 3
+	data := []byte("Ñandú " + testData)
+	brr := NewReaderBufferer(8, 2, 500)
+	brr.SetMaxSize(10) // does not apply to unbuffered data
+
+	newWrapped := func() *BufferedReader {
+		t.Helper()
+		src := bytes.NewReader(data)
+		_, err := src.Seek(prefix, io.SeekStart)
+		zero(t, err, "Seek source")
+		br, err := brr.WrapSeeker(src)
+		zero(t, err, "WrapSeeker")
+		_, ok := br.reader.(*seekReader)
+		equal(t, true, ok, "should not buffer large seekable data")
+		return br
+	}
+
+	br := newWrapped()
+	equal(t, len(data)-prefix, br.Len(), "Len")
+	zero(t, iotest.TestReader(br, data[prefix:]), "iotest.TestReader")
+	_, err := br.Seek(0, io.SeekStart)
+	zero(t, err, "Seek")
+	b, err := br.ReadByte()
+	zero(t, err, "ReadByte")
+	equal(t, data[prefix], b, "ReadByte")
+	zero(t, br.UnreadByte(), "UnreadByte")
+	zero(t, br.UnreadByte() == nil, "UnreadByte at the beginning")
+
+	_, err = br.Seek(-int64(len(testData))-4, io.SeekEnd) // at "dú"
+	zero(t, err, "Seek from end")
+	ch, size, err := br.ReadRune()
+	zero(t, err, "ReadRune")
+	equal(t, 'd', ch, "ReadRune ASCII")
+	equal(t, 1, size, "ReadRune ASCII size")
+	ch, size, err = br.ReadRune()
+	zero(t, err, "ReadRune")
+	equal(t, 'ú', ch, "ReadRune multibyte")
+	equal(t, 2, size, "ReadRune multibyte size")
+	zero(t, br.UnreadRune(), "UnreadRune")
+	zero(t, br.UnreadRune() == nil, "second UnreadRune")
+	equal(t, len(testData)+3, br.Len(), "Len after UnreadRune")
+
+	var dst bytes.Buffer
+	n, err := br.WriteTo(&dst)
+	zero(t, err, "WriteTo")
+	equal(t, int64(len(testData)+3), n, "WriteTo bytes")
+	equal(t, "ú "+testData, dst.String(), "WriteTo data")
+	zero(t, br.Append([]byte("x")) == nil, "Append is not supported")
+	zero(t, br.Close(), "Close")
+	zero(t, br.Len(), "Len after Close")
+
+	br = newWrapped()
+	equal(t, string(data[prefix:]), string(br.Bytes()), "Bytes")
+	br = newWrapped()
+	br.Read(make([]byte, 3))
+	equal(t, string(data[prefix+3:]), br.IntoBuffer().String(), "IntoBuffer")
+	br = newWrapped()
+	br.Read(make([]byte, 3))
+	equal(t, string(data[prefix+3:]), string(br.Drain()), "Drain")
+	zero(t, br.Len(), "Len after Drain")
+
+	zero(t, brr.bufPool.Gets(), "should not get buffers from the pool")
+	st := brr.Stats()
+	zero(t, st.N(), "should not put buffers into the pool")
+
+	// small data is buffered
+	brr.SetMaxSize(0)
+	br, err = brr.WrapSeeker(strings.NewReader("hi"))
+	zero(t, err, "WrapSeeker small data")
+	_, ok := br.reader.(*bytes.Reader)
+	equal(t, true, ok, "should buffer small data")
+	equal(t, "hi", string(br.Drain()), "buffered data")
+	st = brr.Stats()
+	equal(t, 1, st.N(), "should put the buffer into the pool")
+
+	// seekers that are not ReaderAt are buffered
+	br, err = brr.WrapSeeker(struct{ io.ReadSeeker }{bytes.NewReader(data)})
+	zero(t, err, "WrapSeeker without ReaderAt")
+	_, ok = br.reader.(*bytes.Reader)
+	equal(t, true, ok, "should buffer data without ReaderAt")
+	equal(t, string(data), string(br.Drain()), "buffered data")
+}
+
+func TestBufferedReaderReadString(t *testing.T) {
+	t.Parallel()
+	longLine := strings.Repeat("x", 1500) + "\n"
+	data := testData + longLine + testData + "no newline at the end"
+
+	assertLines := func(name string, br *BufferedReader) {
+		t.Helper()
+		want := bufio.NewReader(strings.NewReader(data))
+		for i := 0; ; i++ {
+			wantLine, wantErr := want.ReadString('\n')
+			gotLine, gotErr := br.ReadString('\n')
+			equal(t, wantLine, gotLine, "%s: line #%d", name, i)
+			equal[error](t, wantErr, gotErr, "%s: error #%d", name, i)
+			if wantErr != nil {
+				break
+			}
+		}
+		line, err := br.ReadBytes('\n')
+		zero(t, len(line), "%s: ReadBytes at the end", name)
+		equal[error](t, io.EOF, err, "%s: ReadBytes at the end", name)
+
+		zero(t, br.Close(), "%s: Close", name)
+		wantLine, wantErr := bufio.NewReader(strings.NewReader("")).
+			ReadString('\n')
+		gotLine, gotErr := br.ReadString('\n')
+		equal(t, wantLine, gotLine, "%s: ReadString after Close", name)
+		equal[error](t, wantErr, gotErr, "%s: ReadString after Close", name)
+		line, err = br.ReadBytes('\n')
+		zero(t, line, "%s: ReadBytes after Close", name)
+		equal[error](t, io.EOF, err, "%s: ReadBytes after Close", name)
+	}
+
+	brr := NewReaderBufferer(8, 2, 500)
+	br, err := brr.Reader(strings.NewReader(data))
+	zero(t, err, "Reader")
+	assertLines("buffered", br)
+
+	br, err = brr.Reader(strings.NewReader(data))
+	zero(t, err, "Reader")
+	line, err := br.ReadBytes('\n')
+	zero(t, err, "ReadBytes")
+	clear(br.buf)
+	equal(t, "痛苦\n", string(line), "ReadBytes should return a copy")
+	br.Close()
+
+	brr = NewReaderBufferer(8, 2, 500)
+	br, err = brr.WrapSeeker(strings.NewReader(data))
+	zero(t, err, "WrapSeeker")
+	_, ok := br.reader.(*seekReader)
+	equal(t, true, ok, "should not buffer large seekable data")
+	assertLines("unbuffered", br)
+}
+
+func TestBufferedReaderDrain(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(512, 2, 500)
+
+	br, err := brr.Reader(strings.NewReader(testData))
+	zero(t, err, "Reader")
+	p := make([]byte, 10)
+	_, err = io.ReadFull(br, p)
+	zero(t, err, "ReadFull")
+
+	equal(t, testData[10:], string(br.Drain()), "unread bytes")
+	zero(t, br.Len(), "Len after Drain")
+	st := brr.Stats()
+	equal(t, 1, st.N(), "should have been put back into the pool")
+	equal(t, float64(len(testData)), st.Mean(), "size of the buffer")
+	zero(t, br.Drain(), "Drain after Drain")
+
+	br, err = brr.Reader(strings.NewReader(testData))
+	zero(t, err, "Reader")
+	_, err = io.Copy(io.Discard, br)
+	zero(t, err, "read all")
+	equal(t, 0, len(br.Drain()), "no unread bytes")
+}
+
+func TestBufferedReaderIntoBuffer(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(512, 2, 500)
+
+	br, err := brr.Reader(strings.NewReader(testData))
+	zero(t, err, "Reader")
+	p := make([]byte, 10)
+	_, err = io.ReadFull(br, p)
+	zero(t, err, "ReadFull")
+	internal := br.buf
+
+	buf := br.IntoBuffer()
+	equal(t, testData[10:], buf.String(), "unread bytes")
+	equal(t, &internal[10], &buf.Bytes()[0], "should not copy the data")
+	zero(t, br.Len(), "Len after IntoBuffer")
+	st := brr.Stats()
+	zero(t, st.N(), "should not have been put back into the pool")
+	zero(t, br.IntoBuffer().Len(), "IntoBuffer after IntoBuffer")
+	zero(t, br.Close(), "Close after IntoBuffer")
+	st = brr.Stats()
+	zero(t, st.N(), "Close should be a no-op")
+}
+
+func TestBufferedReaderAppend(t *testing.T) {
+	t.Parallel()
+	const extra = "Heaven knows I'm miserable now"
+
+	brr := NewReaderBufferer(len(testData)+len(extra), 2, 500)
+	br, err := brr.Reader(bytes.NewReader([]byte(testData)))
+	zero(t, err, "Reader error")
+
+	prefix := make([]byte, 4)
+	_, err = io.ReadFull(br, prefix)
+	zero(t, err, "read prefix")
+
+	// within capacity
+	origCap := cap(br.buf)
+	zero(t, br.Append([]byte(extra)), "Append within capacity")
+	equal(t, origCap, cap(br.buf), "should not have grown")
+	equal(t, len(testData)+len(extra)-len(prefix), br.Len(), "unread bytes")
+
+	// beyond capacity
+	big := bytes.Repeat([]byte("x"), origCap)
+	zero(t, br.Append(big), "Append beyond capacity")
+	equal(t, true, cap(br.buf) > origCap, "should have grown")
+
+	rest, err := io.ReadAll(br)
+	zero(t, err, "read rest")
+	want := testData[len(prefix):] + extra + string(big)
+	equal(t, want, string(rest), "read data after Append")
+
+	_, err = br.Seek(0, io.SeekStart)
+	zero(t, err, "Seek")
+	all, err := io.ReadAll(br)
+	zero(t, err, "read all")
+	equal(t, testData+extra+string(big), string(all), "all data")
+
+	zero(t, br.Close(), "Close")
+	st := brr.Stats()
+	equal(t, 1, st.N(), "should have been put back into the pool")
+	equal(t, float64(len(all)), st.Mean(), "should account for growth")
+
+	equal(t, true, br.Append([]byte(extra)) != nil,
+		"Append should fail after Close")
+}
+
 type readCloser struct {
 	io.Reader
 	io.Closer
@@ -125,7 +689,7 @@ func newTestBufferedReader(buf []byte) *BufferedReader {
 	return &BufferedReader{
 		reader:  bytes.NewReader(buf),
 		buf:     buf,
-		release: func([]byte, *bytes.Reader) {},
+		release: func([]byte) {},
 	}
 }
 
@@ -337,3 +901,233 @@ func finishAndTestBufferedReaderInternal(t *testing.T, br *BufferedReader,
 		finishAndTestBufferedReaderInternal(t, br, !closeFirst, false)
 	}
 }
+
+func TestRewindableReader(t *testing.T) {
+	t.Parallel()
+
+	var releases int
+	br := newTestBufferedReader([]byte(testData))
+	br.release = func([]byte) { releases++ }
+	rr := NewRewindableReader(br)
+
+	prefix := make([]byte, 10)
+	_, err := io.ReadFull(rr, prefix)
+	zero(t, err, "read prefix")
+	equal(t, testData[:10], string(prefix), "prefix")
+
+	for i := 0; i < 2; i++ {
+		all, err := io.ReadAll(rr.Body())
+		zero(t, err, "[#%d] read downstream body", i)
+		equal(t, testData, string(all), "[#%d] downstream body", i)
+	}
+
+	zero(t, rr.Body().Close(), "downstream Close")
+	zero(t, rr.Close(), "Close")
+	equal(t, 1, releases, "should have released only once")
+
+	n, err := rr.Read(prefix)
+	equal(t, 0, n, "bytes read after Close")
+	equal(t, io.EOF, err, "Read after Close")
+}
+
+// flakyReader reads from Reader, but fails with Err after each Every bytes, up
+// to Failures times.
+type flakyReader struct {
+	Reader   io.Reader
+	Err      error
+	Every    int
+	Failures int
+	read     int
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if r.Failures > 0 && r.read >= r.Every {
+		r.Failures--
+		r.read = 0
+		return 0, r.Err
+	}
+	if r.Failures > 0 {
+		p = p[:min(len(p), r.Every-r.read)]
+	}
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestReaderBuffererRetry(t *testing.T) {
+	t.Parallel()
+	errTransient := errors.New("transient error")
+	errFatal := errors.New("fatal error")
+	retryable := func(err error) bool {
+		return errors.Is(err, errTransient)
+	}
+
+	brr := NewReaderBufferer(16, 2, 500)
+	brr.SetRetry(2, retryable)
+
+	br, err := brr.Reader(&flakyReader{
+		Reader:   bytes.NewReader([]byte(testData)),
+		Err:      errTransient,
+		Every:    10,
+		Failures: 1,
+	})
+	zero(t, err, "should succeed after one retry")
+	got, err := io.ReadAll(br)
+	zero(t, err, "ReadAll")
+	equal(t, testData, string(got), "should have buffered the full content")
+	zero(t, br.Close(), "Close")
+
+	_, err = brr.Reader(&flakyReader{
+		Reader:   bytes.NewReader([]byte(testData)),
+		Err:      errTransient,
+		Every:    10,
+		Failures: 3,
+	})
+	equal(t, true, errors.Is(err, errTransient),
+		"should fail after exhausting retries")
+	st := brr.Stats()
+	equal(t, 2, st.N(), "buffer should have been put back into the pool")
+	equal(t, (float64(len(testData))+30)/2, st.Mean(),
+		"partially read data should have been preserved across retries")
+
+	_, err = brr.Reader(&flakyReader{
+		Reader:   bytes.NewReader([]byte(testData)),
+		Err:      errFatal,
+		Every:    10,
+		Failures: 1,
+	})
+	equal(t, true, errors.Is(err, errFatal),
+		"should not retry non-retryable errors")
+}
+
+// cancelReader reads from Reader in chunks of at most Chunk bytes, and calls
+// Cancel after Reads calls to Read.
+type cancelReader struct {
+	Reader io.Reader
+	Chunk  int
+	Reads  int
+	Cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if r.Reads--; r.Reads == 0 {
+		r.Cancel()
+	}
+	return r.Reader.Read(p[:min(len(p), r.Chunk)])
+}
+
+func TestReaderBuffererContext(t *testing.T) {
+	t.Parallel()
+
+	brr := NewReaderBufferer(16, 2, 500)
+	br, err := brr.ReaderContext(context.Background(),
+		bytes.NewReader([]byte(testData)))
+	zero(t, err, "ReaderContext error")
+	got, err := io.ReadAll(br)
+	zero(t, err, "ReadAll")
+	equal(t, testData, string(got), "buffered data")
+	zero(t, br.Close(), "Close")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	br, err = brr.ReaderContext(ctx, &cancelReader{
+		Reader: bytes.NewReader([]byte(testData)),
+		Chunk:  10,
+		Reads:  3,
+		Cancel: cancel,
+	})
+	zero(t, br, "should return nil on error")
+	equal(t, true, errors.Is(err, context.Canceled),
+		"should fail with context.Canceled")
+	st := brr.Stats()
+	equal(t, 2, st.N(), "buffer should have been put back into the pool")
+	equal(t, (float64(len(testData))+30)/2, st.Mean(),
+		"partially filled buffer should have been put back")
+
+	var closed bool
+	ctx, cancel = context.WithCancel(context.Background())
+	br, err = brr.ReadCloserContext(ctx, readCloser{
+		Reader: &cancelReader{
+			Reader: bytes.NewReader([]byte(testData)),
+			Chunk:  10,
+			Reads:  1,
+			Cancel: cancel,
+		},
+		Closer: closerFunc(func() error {
+			closed = true
+			return nil
+		}),
+	})
+	zero(t, br, "should return nil on error")
+	equal(t, true, errors.Is(err, context.Canceled),
+		"should fail with context.Canceled")
+	equal(t, true, closed, "should have closed the io.ReadCloser")
+}
+
+func TestReaderBuffererMaxSize(t *testing.T) {
+	t.Parallel()
+
+	brr := NewReaderBufferer(16, 2, 500)
+	brr.SetMaxSize(len(testData))
+	br, err := brr.Reader(bytes.NewReader([]byte(testData)))
+	zero(t, err, "should succeed at the maximum size")
+	zero(t, br.Close(), "Close")
+
+	br, err = brr.Reader(bytes.NewReader([]byte(testData + "x")))
+	zero(t, br, "should return nil on error")
+	equal(t, true, errors.Is(err, ErrMaxSizeExceeded),
+		"should fail exceeding the maximum size")
+	st := brr.Stats()
+	equal(t, 2, st.N(), "buffer should have been put back into the pool")
+
+	var closed bool
+	_, err = brr.ReadCloser(readCloser{
+		Reader: bytes.NewReader([]byte(testData + "x")),
+		Closer: closerFunc(func() error {
+			closed = true
+			return nil
+		}),
+	})
+	equal(t, true, errors.Is(err, ErrMaxSizeExceeded),
+		"should fail exceeding the maximum size")
+	equal(t, true, closed, "should have closed the io.ReadCloser")
+
+	brr.SetMaxSize(0)
+	br, err = brr.Reader(bytes.NewReader([]byte(testData + "x")))
+	zero(t, err, "should not limit the size")
+	zero(t, br.Close(), "Close")
+}
+
+func TestBufferedReaderReadAt(t *testing.T) {
+	t.Parallel()
+
+	br := newTestBufferedReader([]byte(testData))
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(testData))
+	for off := range len(testData) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 5)
+			n, err := br.ReadAt(p, int64(off))
+			want := testData[off:min(off+len(p), len(testData))]
+			if string(p[:n]) != want || n < len(p) && err != io.EOF ||
+				n == len(p) && err != nil {
+				errCh <- fmt.Errorf("ReadAt offset %d: want %q, got %q; "+
+					"error: %v", off, want, p[:n], err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+	equal(t, len(testData), br.Len(), "ReadAt should not affect Read")
+
+	zero(t, br.Close(), "Close")
+	n, err := br.ReadAt(make([]byte, 5), 0)
+	equal(t, 0, n, "bytes read after Close")
+	equal(t, io.EOF, err, "ReadAt after Close")
+	_, err = br.ReadAt(make([]byte, 5), -1)
+	equal(t, true, err != nil, "should fail with negative offset")
+}
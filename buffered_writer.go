@@ -0,0 +1,155 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// WriterBufferer provides [PooledBuffer]s to buffer outgoing data that, upon
+// calling their `Close` method, will put their internal [bytes.Buffer] back
+// into an [AdaptivePool] for reuse. It is the counterpart of [ReaderBufferer].
+type WriterBufferer struct {
+	bufPool AdaptivePool[*bytes.Buffer]
+}
+
+// NewWriterBufferer returns a new WriterBufferer. The `minCap` and `thresh`
+// arguments will be the values of the internal [NormalBytesBuffer.MinCap] and
+// [NormalBytesBuffer.Threshold], respectively. Example:
+//
+//	wb := NewWriterBufferer(512, 2, 500)
+func NewWriterBufferer(minCap int, thresh, maxN float64) *WriterBufferer {
+	wb := new(WriterBufferer)
+	wb.bufPool.init(NormalBytesBuffer{
+		MinCap:    minCap,
+		Threshold: thresh,
+	}, maxN)
+	return wb
+}
+
+// Stats returns the statistics from the internal AdaptivePool.
+func (p *WriterBufferer) Stats() Stats {
+	return p.bufPool.Stats()
+}
+
+// Writer returns an empty PooledBuffer.
+func (p *WriterBufferer) Writer() *PooledBuffer {
+	buf := p.bufPool.Get()
+	buf.Reset()
+	return &PooledBuffer{
+		buf:     buf,
+		release: p.release,
+	}
+}
+
+func (p *WriterBufferer) release(buf *bytes.Buffer, peak int) {
+	// the statistics are updated with the peak Len instead of the current one,
+	// and the data is cleared so that it doesn't leak
+	buf.Reset()
+	clear(buf.AvailableBuffer()[:peak])
+	size := float64(peak)
+	if buf.Cap() == 0 {
+		size = -1 // dropped, the same as Put would do
+	}
+	p.bufPool.putSize(buf, size)
+}
+
+// PooledBuffer is a write buffer obtained from a [WriterBufferer]. Data is
+// written with its `Write*` and `ReadFrom` methods, and then flushed to a
+// destination with WriteTo. Its `Close` method puts the internal buffer back
+// for reuse, updating the statistics with the maximum `Len` it had, and after
+// that it will be empty. It is not safe for concurrent use.
+type PooledBuffer struct {
+	buf     *bytes.Buffer
+	peak    int
+	release func(*bytes.Buffer, int)
+}
+
+// errPooledBufferClosed is returned by the methods that write to a closed
+// PooledBuffer.
+var errPooledBufferClosed = errors.New("PooledBuffer: resource closed")
+
+// Len returns the number of unread bytes.
+func (pb *PooledBuffer) Len() int {
+	if pb.buf != nil {
+		return pb.buf.Len()
+	}
+	return 0
+}
+
+// Bytes returns the unread bytes. The slice is only valid until the next call
+// to any other method.
+func (pb *PooledBuffer) Bytes() []byte {
+	if pb.buf != nil {
+		return pb.buf.Bytes()
+	}
+	return nil
+}
+
+// Write is part of the implementation of the io.Writer interface.
+func (pb *PooledBuffer) Write(p []byte) (int, error) {
+	if pb.buf == nil {
+		return 0, errPooledBufferClosed
+	}
+	return pb.buf.Write(p)
+}
+
+// WriteString is part of the implementation of the io.StringWriter interface.
+func (pb *PooledBuffer) WriteString(s string) (int, error) {
+	if pb.buf == nil {
+		return 0, errPooledBufferClosed
+	}
+	return pb.buf.WriteString(s)
+}
+
+// WriteByte is part of the implementation of the io.ByteWriter interface.
+func (pb *PooledBuffer) WriteByte(c byte) error {
+	if pb.buf == nil {
+		return errPooledBufferClosed
+	}
+	return pb.buf.WriteByte(c)
+}
+
+// ReadFrom is part of the implementation of the io.ReaderFrom interface.
+func (pb *PooledBuffer) ReadFrom(r io.Reader) (int64, error) {
+	if pb.buf == nil {
+		return 0, errPooledBufferClosed
+	}
+	return pb.buf.ReadFrom(r)
+}
+
+// WriteTo is part of the implementation of the io.WriterTo interface. It
+// flushes the buffered data to `w`, leaving the buffer empty unless an error
+// occurs.
+func (pb *PooledBuffer) WriteTo(w io.Writer) (int64, error) {
+	if pb.buf == nil {
+		return 0, nil
+	}
+	pb.updatePeak()
+	return pb.buf.WriteTo(w)
+}
+
+// Reset discards the buffered data, so that the PooledBuffer can be used to
+// buffer new data.
+func (pb *PooledBuffer) Reset() {
+	if pb.buf != nil {
+		pb.updatePeak()
+		pb.buf.Reset()
+	}
+}
+
+func (pb *PooledBuffer) updatePeak() {
+	pb.peak = max(pb.peak, pb.buf.Len())
+}
+
+// Close is part of the implementation of the io.Closer interface. This method
+// releases the internal buffer for reuse. After this, the *PooledBuffer will
+// be empty, and subsequent calls to Close are a no-op.
+func (pb *PooledBuffer) Close() error {
+	if pb.buf != nil {
+		pb.updatePeak()
+		pb.release(pb.buf, pb.peak)
+		*pb = PooledBuffer{}
+	}
+	return nil
+}
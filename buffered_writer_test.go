@@ -0,0 +1,126 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var _ interface { // assert interfaces from standard library
+	io.WriteCloser
+	io.StringWriter
+	io.ByteWriter
+	io.ReaderFrom
+	io.WriterTo
+} = (*PooledBuffer)(nil)
+
+func newStackWriterBufferer(minCap int, thresh,
+	maxN float64) (*WriterBufferer, *stackPool) {
+	wb := NewWriterBufferer(minCap, thresh, maxN)
+	sp := new(stackPool)
+	wb.bufPool.setPool(sp)
+	return wb, sp
+}
+
+func TestWriterBufferer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path - write then flush", func(t *testing.T) {
+		t.Parallel()
+		wb, sp := newStackWriterBufferer(512, 2, 500)
+
+		pb := wb.Writer()
+		_, err := pb.WriteString(testData[:4])
+		zero(t, err, "WriteString")
+		_, err = pb.Write([]byte(testData[4:10]))
+		zero(t, err, "Write")
+		zero(t, pb.WriteByte(testData[10]), "WriteByte")
+		_, err = pb.ReadFrom(strings.NewReader(testData[11:]))
+		zero(t, err, "ReadFrom")
+		equal(t, len(testData), pb.Len(), "Len after writing")
+		equal(t, testData, string(pb.Bytes()), "Bytes")
+
+		var dst bytes.Buffer
+		n, err := pb.WriteTo(&dst)
+		zero(t, err, "WriteTo")
+		equal(t, int64(len(testData)), n, "WriteTo bytes")
+		equal(t, testData, dst.String(), "flushed data")
+		zero(t, pb.Len(), "Len after flushing")
+
+		zero(t, pb.Close(), "Close")
+		st := wb.Stats()
+		equal(t, 1, st.N(), "should have been put back into the pool")
+		equal(t, float64(len(testData)), st.Mean(),
+			"should observe the peak Len")
+		equal(t, 1, sp.Len(), "should have retained the buffer")
+
+		buf := sp.Get().(*bytes.Buffer)
+		zero(t, buf.Len(), "retained buffer should be reset")
+		equal(t, string(make([]byte, len(testData))),
+			string(buf.AvailableBuffer()[:len(testData)]),
+			"retained buffer should not leak data")
+		sp.Put(buf)
+
+		pb = wb.Writer()
+		zero(t, pb.Len(), "reused buffer should be empty")
+		pb.Close()
+		st = wb.Stats()
+		equal(t, 2, st.N(), "should have been put back into the pool")
+		equal(t, float64(len(testData))/2, st.Mean(), "empty buffer size")
+	})
+
+	t.Run("peak Len across Reset", func(t *testing.T) {
+		t.Parallel()
+		wb, _ := newStackWriterBufferer(0, 2, 500)
+
+		pb := wb.Writer()
+		pb.WriteString(testData)
+		pb.Reset()
+		pb.WriteString("a")
+		pb.Close()
+		st := wb.Stats()
+		equal(t, float64(len(testData)), st.Mean(),
+			"should observe the peak Len")
+	})
+
+	t.Run("empty buffer without capacity", func(t *testing.T) {
+		t.Parallel()
+		wb, sp := newStackWriterBufferer(0, 2, 500)
+
+		zero(t, wb.Writer().Close(), "Close")
+		st := wb.Stats()
+		zero(t, st.N(), "should not observe buffers without capacity")
+		zero(t, sp.Len(), "should drop buffers without capacity")
+	})
+
+	t.Run("double Close", func(t *testing.T) {
+		t.Parallel()
+		wb, sp := newStackWriterBufferer(512, 2, 500)
+
+		pb := wb.Writer()
+		pb.WriteString(testData)
+		zero(t, pb.Close(), "first Close")
+		zero(t, pb.Close(), "second Close")
+		st := wb.Stats()
+		equal(t, 1, st.N(), "second Close should be a no-op")
+		equal(t, 1, sp.Len(), "second Close should be a no-op")
+
+		zero(t, pb.Len(), "Len after Close")
+		zero(t, pb.Bytes(), "Bytes after Close")
+		pb.Reset()
+		n, err := pb.WriteTo(io.Discard)
+		zero(t, n, "WriteTo after Close")
+		zero(t, err, "WriteTo after Close")
+
+		_, err = pb.Write([]byte(testData))
+		equal(t, true, errors.Is(err, errPooledBufferClosed), "Write")
+		_, err = pb.WriteString(testData)
+		equal(t, true, errors.Is(err, errPooledBufferClosed), "WriteString")
+		err = pb.WriteByte('a')
+		equal(t, true, errors.Is(err, errPooledBufferClosed), "WriteByte")
+		_, err = pb.ReadFrom(strings.NewReader(testData))
+		equal(t, true, errors.Is(err, errPooledBufferClosed), "ReadFrom")
+	})
+}
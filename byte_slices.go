@@ -0,0 +1,87 @@
+package adaptivepool
+
+import (
+	"math"
+	"sync"
+)
+
+// NormalByteSlices is a [PoolItemProvider] for [][]byte items, like batches of
+// messages, whose memory cost is given by the total number of bytes across the
+// inner slices rather than by the number of inner slices. The AdaptivePool
+// learns the distribution of the total bytes, which is used in Accept, while
+// the distribution of the number of inner slices is learned separately to
+// create new items. It operates under the same assumptions as [NormalSlice] on
+// both dimensions. A NormalByteSlices must be created with
+// [NewNormalByteSlices] and it should not be shared by multiple AdaptivePools.
+type NormalByteSlices struct {
+	MinCap    int     // Minimum capacity of a newly created outer slice
+	Threshold float64 // Threshold must be non-negative.
+
+	mu     sync.Mutex
+	counts Stats
+}
+
+// NewNormalByteSlices returns a new NormalByteSlices. See [Stats.SetMaxN] for a
+// description of the `maxN` argument, which applies to the statistics of the
+// number of inner slices, and which should usually be the same as that of the
+// AdaptivePool.
+func NewNormalByteSlices(
+	minCap int,
+	thresh float64,
+	maxN float64,
+) *NormalByteSlices {
+	p := &NormalByteSlices{
+		MinCap:    minCap,
+		Threshold: thresh,
+	}
+	p.counts.SetMaxN(maxN)
+	return p
+}
+
+// Counts returns the Mean and StdDev of the number of inner slices of the
+// measured items.
+func (p *NormalByteSlices) Counts() (mean, stdDev float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts.Mean(), p.counts.StdDev()
+}
+
+// Sizeof returns the sum of the lengths of the inner slices, and adds the
+// length of the outer slice to the statistics of the number of inner slices.
+// It returns -1 if the outer slice has zero capacity.
+func (p *NormalByteSlices) Sizeof(v [][]byte) float64 {
+	if cap(v) == 0 {
+		return -1
+	}
+	var size int
+	for _, b := range v {
+		size += len(b)
+	}
+	p.mu.Lock()
+	p.counts.Push(float64(len(v)))
+	p.mu.Unlock()
+	return float64(size)
+}
+
+// Create returns a new outer slice with length zero and cap `mean + Threshold
+// * stdDev` of the number of inner slices, or MinCap if greater. The `mean`
+// and `stdDev` arguments, which describe the total bytes, are not used, and
+// the inner slices are not allocated since their individual sizes are unknown.
+func (p *NormalByteSlices) Create(mean, stdDev float64) [][]byte {
+	countMean, countStdDev := p.Counts()
+	size := int(math.Ceil(normalCreateSize(countMean, countStdDev,
+		p.Threshold)))
+	return make([][]byte, 0, max(size, p.MinCap))
+}
+
+// Accept will accept a new item if its total bytes are in the inclusive range
+// `mean ± Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p *NormalByteSlices) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p *NormalByteSlices) Validate() error {
+	return validateThresholds("NormalByteSlices", p.Threshold)
+}
@@ -0,0 +1,55 @@
+package adaptivepool
+
+import (
+	"math"
+	"testing"
+)
+
+var _ PoolItemProvider[[][]byte] = (*NormalByteSlices)(nil)
+
+func byteSlices(sizes ...int) [][]byte {
+	v := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		v[i] = make([]byte, size)
+	}
+	return v
+}
+
+func TestNormalByteSlices(t *testing.T) {
+	t.Parallel()
+	p := NewNormalByteSlices(2, 1, 500)
+
+	v := p.Create(0, math.NaN())
+	zero(t, len(v), "len before measuring")
+	equal(t, 2, cap(v), "should have MinCap before measuring")
+	equal(t, -1, p.Sizeof(nil), "Sizeof nil")
+
+	ap, sp := newStackAdaptivePool[[][]byte](p, 500)
+	// counts: 4, 4, 6, 6 ; total bytes: 100, 100, 300, 300
+	ap.Put(byteSlices(10, 20, 30, 40))
+	ap.Put(byteSlices(1, 1, 1, 97))
+	ap.Put(byteSlices(50, 50, 50, 50, 50, 50))
+	ap.Put(byteSlices(250, 10, 10, 10, 10, 10))
+
+	st := ap.Stats()
+	equal(t, 200, st.Mean(), "total bytes mean")
+	equal(t, 100, st.StdDev(), "total bytes stdDev")
+	countMean, countStdDev := p.Counts()
+	equal(t, 5, countMean, "count mean")
+	equal(t, true, math.Abs(countStdDev-1) < 1e-9, "count stdDev")
+
+	for sp.Len() > 0 {
+		sp.Get()
+	}
+	v = ap.Get()
+	zero(t, len(v), "len of created item")
+	equal(t, 6, cap(v), "cap of created item should be based on counts")
+
+	// bands on total bytes, not on the number of inner slices
+	equal(t, true, p.Accept(st.Mean(), st.StdDev(), p.Sizeof(byteSlices(
+		300))), "should accept few large inner slices")
+	equal(t, false, p.Accept(st.Mean(), st.StdDev(), p.Sizeof(byteSlices(
+		100, 100, 100, 100, 1))), "should reject too many bytes")
+	equal(t, false, p.Accept(st.Mean(), st.StdDev(), p.Sizeof(byteSlices(
+		1, 1, 1, 1, 1))), "should reject too few bytes")
+}
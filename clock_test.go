@@ -0,0 +1,88 @@
+package adaptivepool
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a clock whose time only changes with Advance. Its tickers use
+// unbuffered channels, so that Advance blocks until each due tick is received.
+// Since a receiver processes its ticks sequentially, advancing the time again
+// guarantees that the previous tick was fully processed. It is safe for
+// concurrent use.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{
+		c:     make(chan time.Time),
+		stop:  make(chan struct{}),
+		d:     d,
+		next:  c.now.Add(d),
+		clock: c,
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the time forward by `d`, sending the due ticks of all the
+// active tickers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	tickers := append([]*fakeTicker(nil), c.tickers...)
+	c.mu.Unlock()
+
+	for _, t := range tickers {
+		for ; !t.next.After(now); t.next = t.next.Add(t.d) {
+			select {
+			case t.c <- t.next:
+			case <-t.stop:
+			}
+		}
+	}
+}
+
+type fakeTicker struct {
+	c     chan time.Time
+	stop  chan struct{}
+	once  sync.Once
+	d     time.Duration
+	next  time.Time // only accessed by Advance
+	clock *fakeClock
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+		c := t.clock
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, ct := range c.tickers {
+			if ct == t {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				break
+			}
+		}
+	})
+}
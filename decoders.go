@@ -0,0 +1,118 @@
+package adaptivepool
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Supported decompression formats of [NewDecoders].
+const (
+	FormatGzip    = "gzip"
+	FormatDeflate = "deflate"
+)
+
+// Decoder is a decompressor that can be reused by resetting it onto a new
+// compressed source.
+type Decoder interface {
+	io.ReadCloser
+	// Reset discards the state of the Decoder and makes it read the compressed
+	// data from `r`.
+	Reset(r io.Reader) error
+}
+
+// Decoders is a registry of pools of [Decoder]s keyed by format, so that the
+// allocations of decompressors are also reused. It is safe for concurrent use.
+type Decoders struct {
+	mu    sync.RWMutex
+	pools map[string]*AdaptivePool[Decoder]
+}
+
+// NewDecoders returns a new Decoders with FormatGzip and FormatDeflate
+// registered.
+func NewDecoders() *Decoders {
+	d := &Decoders{
+		pools: make(map[string]*AdaptivePool[Decoder]),
+	}
+	d.Register(FormatGzip, newGzipDecoder)
+	d.Register(FormatDeflate, newFlateDecoder)
+	return d
+}
+
+// Register sets the function used to create new Decoders for the given
+// format, replacing any previously registered one and its pooled Decoders. The
+// created Decoders will be Reset before their first use.
+func (d *Decoders) Register(format string, newDecoder func() Decoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pools[format] = New[Decoder](decoderProvider(newDecoder), 0)
+}
+
+func (d *Decoders) pool(format string) *AdaptivePool[Decoder] {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.pools[format]
+}
+
+// Get returns a Decoder for the given format reading from `r`. The Decoder
+// should be passed to Put when no longer used.
+func (d *Decoders) Get(format string, r io.Reader) (Decoder, error) {
+	p := d.pool(format)
+	if p == nil {
+		return nil, fmt.Errorf("Decoders.Get: unregistered format %q", format)
+	}
+	dec := p.Get()
+	if err := dec.Reset(r); err != nil {
+		p.Put(dec)
+		return nil, fmt.Errorf("reset %s decoder: %w", format, err)
+	}
+	return dec, nil
+}
+
+// Put puts the Decoder back into the pool of the given format. It doesn't need
+// to be closed first, since it will be Reset before being reused. Decoders of
+// unregistered formats are dropped.
+func (d *Decoders) Put(format string, dec Decoder) {
+	if p := d.pool(format); p != nil {
+		p.Put(dec)
+	}
+}
+
+// decoderProvider is a PoolItemProvider for Decoders. Decoders have a fixed
+// cost, so they are all measured with a size of zero and always accepted.
+type decoderProvider func() Decoder
+
+func (p decoderProvider) Sizeof(v Decoder) float64 {
+	if v == nil {
+		return -1
+	}
+	return 0
+}
+
+func (p decoderProvider) Create(mean, stdDev float64) Decoder {
+	return p()
+}
+
+func (p decoderProvider) Accept(mean, stdDev, itemSize float64) bool {
+	return true
+}
+
+func newGzipDecoder() Decoder {
+	return new(gzip.Reader)
+}
+
+// flateDecoder adapts the io.ReadCloser returned by flate.NewReader, which also
+// implements flate.Resetter, to a Decoder.
+type flateDecoder struct {
+	io.ReadCloser
+}
+
+func newFlateDecoder() Decoder {
+	return flateDecoder{flate.NewReader(nil)}
+}
+
+func (d flateDecoder) Reset(r io.Reader) error {
+	return d.ReadCloser.(flate.Resetter).Reset(r, nil)
+}
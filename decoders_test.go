@@ -0,0 +1,142 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+var _ Decoder = (*gzip.Reader)(nil)
+
+func compress(t *testing.T, format, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case FormatGzip:
+		w = gzip.NewWriter(&buf)
+	case FormatDeflate:
+		var err error
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		zero(t, err, "flate.NewWriter")
+	}
+	_, err := io.WriteString(w, data)
+	zero(t, err, "compress %s data", format)
+	zero(t, w.Close(), "close %s writer", format)
+	return buf.Bytes()
+}
+
+func TestDecoders(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{FormatGzip, FormatDeflate} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			d := NewDecoders()
+			newDecoder := newGzipDecoder
+			if format == FormatDeflate {
+				newDecoder = newFlateDecoder
+			}
+			var created int
+			d.Register(format, func() Decoder {
+				created++
+				return newDecoder()
+			})
+			d.pools[format].setPool(new(stackPool))
+
+			for i := 0; i < 5; i++ {
+				data := testData + string(rune('a'+i))
+				dec, err := d.Get(format, bytes.NewReader(compress(t, format,
+					data)))
+				zero(t, err, "[#%d] Get", i)
+				got, err := io.ReadAll(dec)
+				zero(t, err, "[#%d] decompress", i)
+				equal(t, data, string(got), "[#%d] decompressed data", i)
+				d.Put(format, dec)
+			}
+			equal(t, 1, created, "decoders should be reused")
+		})
+	}
+
+	d := NewDecoders()
+	_, err := d.Get("zstd", bytes.NewReader(nil))
+	equal(t, true, err != nil, "should fail with unregistered format")
+	_, err = d.Get(FormatGzip, bytes.NewReader([]byte("not gzip")))
+	equal(t, true, err != nil, "should fail with invalid gzip header")
+	d.Put("zstd", newGzipDecoder()) // should be a nop
+	d.Put(FormatGzip, nil)          // should be a nop
+}
+
+func TestReaderBuffererDecode(t *testing.T) {
+	t.Parallel()
+
+	d := NewDecoders()
+	brr := NewReaderBufferer(0, 2, 500)
+	br, err := brr.Decode(d, FormatGzip, bytes.NewReader(compress(t,
+		FormatGzip, testData)))
+	zero(t, err, "Decode")
+	got, err := io.ReadAll(br)
+	zero(t, err, "read decompressed data")
+	equal(t, testData, string(got), "decompressed data")
+	zero(t, br.Close(), "Close")
+
+	_, err = brr.Decode(d, "zstd", bytes.NewReader(nil))
+	equal(t, true, err != nil, "should fail with unregistered format")
+}
+
+func TestReaderBuffererReadCloserGzip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("happy path", func(t *testing.T) {
+		t.Parallel()
+		brr := NewReaderBufferer(0, 2, 500)
+		var closed int
+		rc := readCloser{
+			Reader: bytes.NewReader(compress(t, FormatGzip, testData)),
+			Closer: closerFunc(func() error { closed++; return nil }),
+		}
+		br, err := brr.ReadCloserGzip(rc)
+		zero(t, err, "ReadCloserGzip")
+		equal(t, 1, closed, "should have closed the io.ReadCloser")
+		got, err := io.ReadAll(br)
+		zero(t, err, "read decompressed data")
+		equal(t, testData, string(got), "decompressed data")
+
+		zero(t, br.Close(), "Close")
+		st := brr.Stats()
+		equal(t, 1, st.N(), "should have been put back into the pool")
+		equal(t, float64(len(testData)), st.Mean(),
+			"should observe the decompressed size")
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		t.Parallel()
+		brr := NewReaderBufferer(0, 2, 500)
+		var closed int
+		rc := readCloser{
+			Reader: bytes.NewReader([]byte(testData)),
+			Closer: closerFunc(func() error { closed++; return nil }),
+		}
+		br, err := brr.ReadCloserGzip(rc)
+		equal(t, true, errors.Is(err, gzip.ErrHeader), "should fail reset")
+		zero(t, br, "should return nil on error")
+		equal(t, 1, closed, "should have closed the io.ReadCloser")
+	})
+
+	t.Run("corrupted data", func(t *testing.T) {
+		t.Parallel()
+		brr := NewReaderBufferer(0, 2, 500)
+		data := compress(t, FormatGzip, testData)
+		data[len(data)-5]++ // corrupt the checksum
+		br, err := brr.ReadCloserGzip(io.NopCloser(bytes.NewReader(data)))
+		equal(t, true, errors.Is(err, gzip.ErrChecksum),
+			"should fail reading")
+		zero(t, br, "should return nil on error")
+		st := brr.Stats()
+		equal(t, 1, st.N(), "should have put back the buffer")
+	})
+}
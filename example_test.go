@@ -0,0 +1,69 @@
+package adaptivepool_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/diegommm/adaptivepool"
+)
+
+func ExampleReaderBufferer() {
+	rb := adaptivepool.NewReaderBufferer(512, 2, 500)
+
+	// buffer the contents of an HTTP-like body. The body is always closed
+	body := io.NopCloser(strings.NewReader(`{"status":"ok"}`))
+	br, err := rb.ReadCloser(body)
+	if err != nil {
+		fmt.Println("buffer body:", err)
+		return
+	}
+	fmt.Println("buffered bytes:", br.Len())
+
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(br).Decode(&resp); err != nil {
+		fmt.Println("decode body:", err)
+		return
+	}
+	fmt.Println("status:", resp.Status)
+
+	// the data is in memory, so it can be read again
+	if _, err := br.Seek(0, io.SeekStart); err != nil {
+		fmt.Println("seek:", err)
+		return
+	}
+	raw, _ := io.ReadAll(br)
+	fmt.Println("raw body:", string(raw))
+
+	// Close puts the buffer back for reuse, updating the statistics. After
+	// that, the BufferedReader is empty
+	br.Close()
+	st := rb.Stats()
+	fmt.Println("observations after Close:", st.N())
+	fmt.Println("length after Close:", br.Len())
+
+	// Bytes transfers the ownership of the buffer to the caller, so it is not
+	// put back for reuse, and Close becomes a nop
+	br, err = rb.Reader(strings.NewReader("keep me"))
+	if err != nil {
+		fmt.Println("buffer reader:", err)
+		return
+	}
+	data := br.Bytes()
+	br.Close()
+	st = rb.Stats()
+	fmt.Println("owned data:", string(data))
+	fmt.Println("observations after Bytes:", st.N())
+
+	// Output:
+	// buffered bytes: 15
+	// status: ok
+	// raw body: {"status":"ok"}
+	// observations after Close: 1
+	// length after Close: 0
+	// owned data: keep me
+	// observations after Bytes: 1
+}
@@ -0,0 +1,41 @@
+package adaptivepool
+
+import "unsafe"
+
+// FixedArray is a [PoolItemProvider] for pointers to items of the fixed-size
+// type A, typically an array like [4096]byte. Go doesn't allow the length of
+// an array to be a type parameter, so the array type itself is used instead,
+// e.g. FixedArray[[4096]byte] for *[4096]byte items. Pointers are used because
+// putting an array by value in a [sync.Pool] allocates to box it into an
+// interface. All items are identical, so Sizeof always returns the size of A
+// in bytes, Accept always accepts and the statistics have no effect on Create,
+// which makes the AdaptivePool a simple typed pool.
+type FixedArray[A any] struct{}
+
+// Sizeof returns the size of A in bytes, or -1 if `v` is nil.
+func (p FixedArray[A]) Sizeof(v *A) float64 {
+	if v == nil {
+		return -1
+	}
+	return p.size()
+}
+
+// Create returns a pointer to a new zero A.
+func (p FixedArray[A]) Create(mean, stdDev float64) *A {
+	return new(A)
+}
+
+// CreateSize returns the size of A in bytes.
+func (p FixedArray[A]) CreateSize(mean, stdDev float64) float64 {
+	return p.size()
+}
+
+// Accept always accepts the item.
+func (p FixedArray[A]) Accept(mean, stdDev, itemSize float64) bool {
+	return true
+}
+
+func (p FixedArray[A]) size() float64 {
+	var a A
+	return float64(unsafe.Sizeof(a))
+}
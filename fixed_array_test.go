@@ -0,0 +1,41 @@
+package adaptivepool
+
+import "testing"
+
+func TestFixedArray(t *testing.T) {
+	t.Parallel()
+	const size = 4096
+
+	p := FixedArray[[size]byte]{}
+	equal(t, -1, p.Sizeof(nil), "Sizeof of nil")
+	equal(t, size, p.Sizeof(new([size]byte)), "Sizeof")
+	equal(t, size, p.CreateSize(0, 0), "CreateSize")
+	equal(t, 8*size, FixedArray[[size]int64]{}.CreateSize(0, 0),
+		"CreateSize should be in bytes")
+
+	ap, sp := newStackAdaptivePool[*[size]byte](p, 0)
+	ap.Put(nil)
+	zero(t, sp.Len(), "nil should not be retained")
+	a := ap.Get()
+	a[0] = 1
+	ap.Put(a)
+	ap.Put(new([size]byte))
+	equal(t, 2, sp.Len(), "retained items")
+	st := ap.Stats()
+	equal(t, size, st.Mean(), "Mean")
+	zero(t, st.StdDev(), "StdDev")
+}
+
+func TestFixedArrayAllocs(t *testing.T) {
+	ap := New[*[4096]byte](FixedArray[[4096]byte]{}, 0)
+	ap.Put(ap.Get())
+	// boxing the item in Put would allocate in each run, while the occasional
+	// item dropped by sync.Pool, like with the race detector, is truncated
+	// away from the average
+	allocs := testing.AllocsPerRun(100, func() {
+		a := ap.Get()
+		a[0]++
+		ap.Put(a)
+	})
+	zero(t, allocs, "Get and Put should not allocate")
+}
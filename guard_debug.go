@@ -0,0 +1,22 @@
+//go:build adaptivepool_debug
+
+package adaptivepool
+
+import "sync/atomic"
+
+// useGuard detects concurrent use of types that are not safe for it. This
+// version is only used with the adaptivepool_debug build tag, and panics when
+// an operation starts while another one is in progress.
+type useGuard struct {
+	inUse atomic.Bool
+}
+
+func (g *useGuard) enter(typ string) {
+	if !g.inUse.CompareAndSwap(false, true) {
+		panic("adaptivepool: concurrent use of " + typ)
+	}
+}
+
+func (g *useGuard) exit() {
+	g.inUse.Store(false)
+}
@@ -0,0 +1,78 @@
+//go:build adaptivepool_debug
+
+package adaptivepool
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBufferedReaderConcurrentUse(t *testing.T) {
+	t.Parallel()
+	rb := NewReaderBufferer(512, 2, 500)
+	br, err := rb.Reader(strings.NewReader(testData))
+	equal(t, nil, err, "Reader error")
+
+	calls := map[string]func(){
+		"Read":  func() { br.Read(make([]byte, 1)) },
+		"Seek":  func() { br.Seek(0, 0) },
+		"Close": func() { br.Close() },
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			// simulate an operation in progress
+			br.guard.enter("BufferedReader")
+			defer br.guard.exit()
+			defer func() {
+				equal[any](t, "adaptivepool: concurrent use of BufferedReader",
+					recover(), "%s should panic", name)
+			}()
+			call()
+		})
+	}
+
+	// the guard is released after each call, including after Close resets
+	// the BufferedReader
+	for range 2 {
+		_, err = br.Seek(0, 0)
+		equal(t, nil, err, "Seek error")
+	}
+	equal(t, nil, br.Close(), "first Close error")
+	equal(t, nil, br.Close(), "second Close error")
+}
+
+func TestBufferedReaderConcurrentReads(t *testing.T) {
+	t.Parallel()
+	if runtime.GOMAXPROCS(0) < 2 {
+		t.Skip("needs at least 2 Ps to run Reads in parallel")
+	}
+	rb := NewReaderBufferer(512, 2, 500)
+	br, err := rb.Reader(strings.NewReader(strings.Repeat(testData, 100)))
+	equal(t, nil, err, "Reader error")
+
+	var panicked atomic.Bool
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if recover() != nil {
+					panicked.Store(true)
+				}
+			}()
+			p := make([]byte, 1)
+			for i := 0; i < 1e6 && !panicked.Load(); i++ {
+				if _, err := br.Read(p); err == io.EOF {
+					br.Seek(0, io.SeekStart)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	equal(t, true, panicked.Load(), "concurrent Reads should panic")
+}
@@ -0,0 +1,91 @@
+package adaptivepool
+
+import (
+	"fmt"
+	"math"
+)
+
+// Histogram is a streaming estimator of the distribution of the sizes, which
+// counts the values in buckets whose bounds grow exponentially, so that it can
+// estimate any quantile over a wide range of sizes using a fixed amount of
+// memory. For values in the range [1, maxValue], the estimated quantiles have
+// a relative error of at most the one given to [NewHistogram], compared to the
+// nearest-rank quantile. Values less than 1 are estimated as 1, or as 0 if they
+// are not positive, and values greater than maxValue are estimated as
+// maxValue. Like [Percentile], it does not assume any distribution, but it can
+// estimate many quantiles at once. It is not safe for concurrent use.
+type Histogram struct {
+	logGamma float64 // logarithm of the growth of the bucket bounds
+	maxValue float64
+	n        uint64
+	zeros    uint64   // count of the values that are not positive
+	counts   []uint64 // counts[i] is the count in the range (γ^(i-1), γ^i]
+}
+
+// NewHistogram returns a new Histogram for values up to `maxValue`, with a
+// relative error `relErr` in the range (0, 1). For example, a relative error
+// of 0.01 and a maxValue of 1<<30 use about 8KiB of memory. It panics if
+// `relErr` is not in that range.
+func NewHistogram(relErr, maxValue float64) *Histogram {
+	if !(relErr > 0 && relErr < 1) {
+		panic(fmt.Sprintf("adaptivepool: Histogram relative error must be in "+
+			"the range (0, 1), got %v", relErr))
+	}
+	gamma := (1 + relErr) / (1 - relErr)
+	h := &Histogram{
+		logGamma: math.Log(gamma),
+		maxValue: max(maxValue, 1),
+	}
+	h.counts = make([]uint64, h.index(h.maxValue)+1)
+	return h
+}
+
+// index returns the index of the bucket of a positive value.
+func (h *Histogram) index(v float64) int {
+	return max(int(math.Ceil(math.Log(v)/h.logGamma)), 0)
+}
+
+// N returns the number of values added.
+func (h *Histogram) N() uint64 { return h.n }
+
+// AddSample adds a new value to the estimation. NaNs are ignored.
+func (h *Histogram) AddSample(v float64) {
+	switch {
+	case math.IsNaN(v):
+		return
+	case v <= 0:
+		h.zeros++
+	default:
+		h.counts[h.index(min(v, h.maxValue))]++
+	}
+	h.n++
+}
+
+// Quantile returns the estimated `q`-quantile, with `q` in the range [0, 1].
+// For example, 0.95 estimates the 95th percentile. It returns NaN if no values
+// were added, or if `q` is out of range.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.n == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+	rank := max(uint64(math.Ceil(q*float64(h.n))), 1) // nearest rank
+	count := h.zeros
+	if count >= rank {
+		return 0
+	}
+	for i, c := range h.counts {
+		if count += c; count >= rank {
+			// the value with the lowest relative error to both bounds
+			v := 2 * math.Exp(float64(i)*h.logGamma) /
+				(1 + math.Exp(h.logGamma))
+			return min(max(v, 1), h.maxValue)
+		}
+	}
+	return h.maxValue // unreachable
+}
+
+// Reset clears all the data.
+func (h *Histogram) Reset() {
+	h.n, h.zeros = 0, 0
+	clear(h.counts)
+}
@@ -0,0 +1,90 @@
+package adaptivepool
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+
+	h := NewHistogram(0.01, 1000)
+	equal(t, true, math.IsNaN(h.Quantile(0.5)), "no values")
+	for _, v := range []float64{math.NaN(), 0, -1, 0.5, 1, 2000} {
+		h.AddSample(v)
+	}
+	equal(t, 5, h.N(), "should ignore NaN")
+	equal(t, 0, h.Quantile(0), "not positive values")
+	equal(t, 0, h.Quantile(0.4), "not positive values")
+	equal(t, 1, h.Quantile(0.6), "values less than 1")
+	equal(t, 1, h.Quantile(0.8), "values less than 1")
+	equal(t, 1000, h.Quantile(1), "should cap at maxValue")
+	equal(t, true, math.IsNaN(h.Quantile(1.1)), "q out of range")
+	equal(t, true, math.IsNaN(h.Quantile(-0.1)), "q out of range")
+	h.Reset()
+	zero(t, h.N(), "N after Reset")
+	equal(t, true, math.IsNaN(h.Quantile(0.5)), "no values after Reset")
+
+	const relErr = 0.01
+	values := allTestDataInputValues(t)
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	h = NewHistogram(relErr, sorted[len(sorted)-1])
+	for _, v := range values {
+		h.AddSample(v)
+	}
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.95, 0.99, 1} {
+		want := sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+		got := h.Quantile(q)
+		if err := math.Abs(got-want) / want; err > relErr {
+			t.Fatalf("q=%v: estimation too far from the actual quantile; "+
+				"want: %v, got: %v, relative error: %v", q, want, got, err)
+		}
+	}
+}
+
+func TestNewHistogramRelErr(t *testing.T) {
+	t.Parallel()
+
+	for _, relErr := range []float64{0, -0.1, 1, 2, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				equal(t, true, recover() != nil,
+					"NewHistogram should panic with relErr %v", relErr)
+			}()
+			NewHistogram(relErr, 1000)
+		}()
+	}
+}
+
+func TestAdaptivePoolSetHistogram(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[int](intProvider{}, 0)
+	equal(t, true, math.IsNaN(ap.Quantile(0.5)), "no Histogram")
+	h := NewHistogram(0.01, 1<<20)
+	ap.SetHistogram(h, 0.95)
+	ap.Put(10)
+	sp.Get()
+	equal(t, 10, ap.Get(), "should use the quantile with one value")
+
+	for i := 1; i <= 100; i++ {
+		ap.Put(i * 10)
+	}
+	for sp.Len() > 0 {
+		sp.Get()
+	}
+	want := 950.0 // the 96th of the 101 values
+	if got := float64(ap.Get()); math.Abs(got-want)/want > 0.01 {
+		t.Fatalf("should create items with the quantile: want %v, got %v",
+			want, got)
+	}
+	st := ap.Stats()
+	equal(t, true, ap.Get() > int(st.Mean()), "should not use the mean")
+
+	ap.Reset()
+	zero(t, h.N(), "Reset should clear the Histogram")
+	ap.SetHistogram(nil, 0.95)
+	equal(t, true, math.IsNaN(ap.Quantile(0.5)), "disabled Histogram")
+}
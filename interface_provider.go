@@ -0,0 +1,150 @@
+package adaptivepool
+
+// InterfaceProvider is a [PoolItemProvider] for items of an interface type,
+// whose dynamic values can be of different concrete types, each with its own
+// way of being measured. The size of each item is measured by SizeofFunc, which
+// can use a type switch to measure the concrete value, and new items are
+// created by CreateFunc. Items are accepted under the assumption that their
+// sizes follow a Normal Distribution, as with [NormalSlice]. Example:
+//
+//	p := InterfaceProvider[io.ReadWriter]{
+//		SizeofFunc: func(v io.ReadWriter) float64 {
+//			switch v := v.(type) {
+//			case *bytes.Buffer:
+//				return float64(v.Cap())
+//			case *myBuffer:
+//				return float64(v.Size())
+//			}
+//			return -1
+//		},
+//		CreateFunc: func(mean, stdDev float64) io.ReadWriter {
+//			return bytes.NewBuffer(make([]byte, 0, int(mean)))
+//		},
+//		Threshold: 2,
+//	}
+type InterfaceProvider[T any] struct {
+	// SizeofFunc measures non-nil items, with the same semantics as
+	// [PoolItemProvider.Sizeof]. Required.
+	SizeofFunc func(T) float64
+	// CreateFunc has the same semantics as [PoolItemProvider.Create]. Required.
+	CreateFunc func(mean, stdDev float64) T
+	Threshold  float64 // Threshold must be non-negative.
+}
+
+// Sizeof returns -1 for nil items, and the result of SizeofFunc otherwise.
+func (p InterfaceProvider[T]) Sizeof(v T) float64 {
+	if any(v) == nil {
+		return -1
+	}
+	return p.SizeofFunc(v)
+}
+
+// Create returns the result of CreateFunc.
+func (p InterfaceProvider[T]) Create(mean, stdDev float64) T {
+	return p.CreateFunc(mean, stdDev)
+}
+
+// Accept will accept a new item if its size is in the inclusive range `mean ±
+// Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p InterfaceProvider[T]) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p InterfaceProvider[T]) Validate() error {
+	return validateThresholds("InterfaceProvider", p.Threshold)
+}
+
+// FuncProvider is a [PoolItemProvider] built from functions, which avoids
+// writing a new type for items measured by a domain-specific metric, like the
+// number of pending tasks of a request. The constructors [NewFuncProvider],
+// [NewPercentileFuncProvider] and [NewStrategyFuncProvider] set AcceptFunc for
+// the common retention policies.
+type FuncProvider[T any] struct {
+	// SizeofFunc has the same semantics as [PoolItemProvider.Sizeof].
+	// Required.
+	SizeofFunc func(T) float64
+	// CreateFunc has the same semantics as [PoolItemProvider.Create].
+	// Required.
+	CreateFunc func(mean, stdDev float64) T
+	// AcceptFunc has the same semantics as [PoolItemProvider.Accept].
+	// Required.
+	AcceptFunc func(mean, stdDev, itemSize float64) bool
+
+	strategy AcceptStrategy // set by the constructors, used by Validate
+}
+
+// NewFuncProvider returns a FuncProvider that accepts items under the
+// assumption that their sizes follow a Normal Distribution, using a
+// [NormalBand] with the given threshold, like [NormalSlice]. Example:
+//
+//	p := NewFuncProvider(
+//		func(r *Request) float64 { return float64(cap(r.Tasks)) },
+//		2,
+//		func(mean, stdDev float64) *Request {
+//			return &Request{Tasks: make([]Task, 0, int(mean))}
+//		},
+//	)
+func NewFuncProvider[T any](
+	sizeof func(T) float64,
+	threshold float64,
+	create func(mean, stdDev float64) T,
+) FuncProvider[T] {
+	return NewStrategyFuncProvider(sizeof, NormalBand{Threshold: threshold},
+		create)
+}
+
+// NewPercentileFuncProvider returns a FuncProvider that accepts items whose
+// size is at most the estimated percentile `p` of the sizes put, using a new
+// [PercentileBound], like [PercentileSlice]. It makes no assumption about the
+// distribution of the sizes. The returned FuncProvider should not be shared by
+// multiple [AdaptivePool]s.
+func NewPercentileFuncProvider[T any](
+	sizeof func(T) float64,
+	p float64,
+	create func(mean, stdDev float64) T,
+) FuncProvider[T] {
+	return NewStrategyFuncProvider(sizeof, NewPercentileBound(p), create)
+}
+
+// NewStrategyFuncProvider returns a FuncProvider that accepts items with the
+// given [AcceptStrategy], like [AcceptAll].
+func NewStrategyFuncProvider[T any](
+	sizeof func(T) float64,
+	s AcceptStrategy,
+	create func(mean, stdDev float64) T,
+) FuncProvider[T] {
+	return FuncProvider[T]{
+		SizeofFunc: sizeof,
+		CreateFunc: create,
+		AcceptFunc: s.Accept,
+		strategy:   s,
+	}
+}
+
+// Sizeof returns the result of SizeofFunc.
+func (p FuncProvider[T]) Sizeof(v T) float64 {
+	return p.SizeofFunc(v)
+}
+
+// Create returns the result of CreateFunc.
+func (p FuncProvider[T]) Create(mean, stdDev float64) T {
+	return p.CreateFunc(mean, stdDev)
+}
+
+// Accept returns the result of AcceptFunc.
+func (p FuncProvider[T]) Accept(mean, stdDev, itemSize float64) bool {
+	return p.AcceptFunc(mean, stdDev, itemSize)
+}
+
+// Validate returns the error from the Validate method of the AcceptStrategy
+// given to the constructor, if it implements [Validator], like one wrapping
+// ErrInvalidThreshold for a negative or NaN threshold given to
+// NewFuncProvider.
+func (p FuncProvider[T]) Validate() error {
+	if v, ok := p.strategy.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
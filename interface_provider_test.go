@@ -0,0 +1,189 @@
+package adaptivepool
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+var (
+	_ PoolItemProvider[io.Closer] = InterfaceProvider[io.Closer]{}
+	_ PoolItemProvider[*request]  = FuncProvider[*request]{}
+)
+
+// smallCloser is measured by the capacity of its buffer.
+type smallCloser struct {
+	buf []byte
+}
+
+func (*smallCloser) Close() error { return nil }
+
+// chunkedCloser is measured by the total capacity of its chunks.
+type chunkedCloser struct {
+	chunks [][]byte
+}
+
+func (*chunkedCloser) Close() error { return nil }
+
+func TestInterfaceProvider(t *testing.T) {
+	t.Parallel()
+
+	var unknownSizes int
+	p := InterfaceProvider[io.Closer]{
+		SizeofFunc: func(v io.Closer) float64 {
+			switch v := v.(type) {
+			case *smallCloser:
+				return float64(cap(v.buf))
+			case *chunkedCloser:
+				var size int
+				for _, c := range v.chunks {
+					size += cap(c)
+				}
+				return float64(size)
+			}
+			unknownSizes++
+			return -1
+		},
+		CreateFunc: func(mean, stdDev float64) io.Closer {
+			return &smallCloser{
+				buf: make([]byte, 0, int(normalCreateSize(mean, stdDev, 1))),
+			}
+		},
+		Threshold: 1,
+	}
+
+	equal(t, -1, p.Sizeof(nil), "nil item")
+	zero(t, unknownSizes, "SizeofFunc should not be called for nil items")
+	equal(t, -1, p.Sizeof(io.NopCloser(nil)), "unknown item")
+	equal(t, 1, unknownSizes, "SizeofFunc should be called for unknown items")
+	equal(t, 10, p.Sizeof(&smallCloser{buf: make([]byte, 10)}), "smallCloser")
+	equal(t, 30, p.Sizeof(&chunkedCloser{chunks: [][]byte{
+		make([]byte, 10),
+		make([]byte, 20),
+	}}), "chunkedCloser")
+
+	ap, sp := newStackAdaptivePool[io.Closer](p, 0)
+	ap.Put(nil)
+	ap.Put(&smallCloser{buf: make([]byte, 40)})
+	ap.Put(&chunkedCloser{chunks: [][]byte{
+		make([]byte, 30),
+		make([]byte, 30),
+	}})
+	equal(t, 2, sp.Len(), "retained items")
+
+	st := ap.Stats()
+	equal(t, 2, st.N(), "N")
+	equal(t, 50, st.Mean(), "Mean")
+	equal(t, 10, st.StdDev(), "StdDev")
+
+	sp.items = nil
+	got, ok := ap.Get().(*smallCloser)
+	equal(t, true, ok, "created item type")
+	equal(t, 60, cap(got.buf), "created item size")
+	equal(t, true, p.Accept(50, 10, 60), "Accept within band")
+	equal(t, false, p.Accept(50, 10, 61), "Accept outside band")
+	equal(t, true, p.Accept(50, math.NaN(), 100), "Accept with NaN StdDev")
+}
+
+// request is measured by the number of its pending tasks.
+type request struct {
+	pending []int
+}
+
+func sizeofRequest(r *request) float64 {
+	if r == nil {
+		return -1
+	}
+	return float64(len(r.pending))
+}
+
+func createRequest(mean, stdDev float64) *request {
+	size := int(normalCreateSize(mean, stdDev, 1))
+	return &request{pending: make([]int, 0, size)}
+}
+
+func TestFuncProvider(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewChecked[*request](NewFuncProvider(sizeofRequest, -1,
+		createRequest), 0)
+	equal(t, true, errors.Is(err, ErrInvalidThreshold),
+		"NewChecked should fail with a negative threshold: %v", err)
+
+	p := NewFuncProvider(sizeofRequest, 1, createRequest)
+	ap, sp := newStackAdaptivePool[*request](p, 0)
+	ap.Put(nil) // should be a nop
+	zero(t, sp.Len(), "nil request should not be retained")
+
+	// same sizes as in TestNormalMap
+	for i, c := range []struct {
+		size     int
+		accepted bool
+	}{
+		{10, true}, {10, true}, {10, true}, {20, false}, {20, false},
+		{20, true},
+	} {
+		retained := sp.Len()
+		ap.Put(&request{pending: make([]int, c.size)})
+		equal(t, c.accepted, sp.Len() > retained, "[#%d] accepted", i)
+	}
+	for sp.Len() > 0 {
+		sp.Get()
+	}
+	equal(t, 20, cap(ap.Get().pending), "capacity of created request")
+
+	var accepted []float64
+	p.AcceptFunc = func(mean, stdDev, itemSize float64) bool {
+		accepted = append(accepted, itemSize)
+		return false
+	}
+	ap, sp = newStackAdaptivePool[*request](p, 0)
+	ap.Put(&request{pending: make([]int, 5)})
+	equal(t, 1, len(accepted), "AcceptFunc should be called")
+	equal(t, 5, accepted[0], "size passed to AcceptFunc")
+	zero(t, sp.Len(), "request should be dropped by AcceptFunc")
+}
+
+func TestNewPercentileFuncProvider(t *testing.T) {
+	t.Parallel()
+
+	p := NewPercentileFuncProvider(sizeofRequest, 0.9, createRequest)
+	ap, sp := newStackAdaptivePool[*request](p, 0)
+	ap.Put(nil) // should be a nop
+	zero(t, sp.Len(), "nil request should not be retained")
+
+	for i := range 100 {
+		ap.Put(&request{pending: make([]int, 10+i%10)})
+	}
+	retained := sp.Len()
+	equal(t, true, retained > 80, "most requests should be retained: %v",
+		retained)
+	ap.Put(&request{pending: make([]int, 1e4)})
+	equal(t, retained, sp.Len(), "the outlier should be dropped")
+
+	// each FuncProvider has its own estimation
+	ap, sp = newStackAdaptivePool[*request](NewPercentileFuncProvider(
+		sizeofRequest, 0.9, createRequest), 0)
+	ap.Put(&request{pending: make([]int, 1e4)})
+	equal(t, 1, sp.Len(), "first request should be retained")
+}
+
+func TestNewStrategyFuncProvider(t *testing.T) {
+	t.Parallel()
+
+	p := NewStrategyFuncProvider(sizeofRequest, AcceptAll{}, createRequest)
+	ap, sp := newStackAdaptivePool[*request](p, 0)
+	for _, size := range []int{10, 10, 10, 1e4, 0} {
+		ap.Put(&request{pending: make([]int, size)})
+	}
+	equal(t, 5, sp.Len(), "AcceptAll should retain every request")
+
+	p = NewStrategyFuncProvider(sizeofRequest, AcceptNone{}, createRequest)
+	ap, sp = newStackAdaptivePool[*request](p, 0)
+	for _, size := range []int{10, 10, 10} {
+		ap.Put(&request{pending: make([]int, size)})
+	}
+	zero(t, sp.Len(), "AcceptNone should drop every request")
+	equal(t, 10, cap(ap.Get().pending), "capacity of created request")
+}
@@ -0,0 +1,70 @@
+package adaptivepool
+
+import (
+	"expvar"
+	"math"
+)
+
+// Metrics is a snapshot of the statistics and usage counters of an
+// [AdaptivePool]. The Put methods are Put, PutForce and PutObserve, as well as
+// the ones used internally by [ReaderBufferer] to put buffers back.
+type Metrics struct {
+	N, Mean, StdDev float64
+
+	Gets   uint64 // calls to Get and GetWait
+	Puts   uint64 // calls to any of the Put methods
+	Drops  uint64 // items passed to a Put method that were not retained
+	Misses uint64 // items created because the pool had none available
+}
+
+// Metrics returns a snapshot of the statistics and usage counters of the pool.
+// The counters are maintained with atomic operations, so they don't add lock
+// contention, but they are read independently from each other.
+func (p *AdaptivePool[T]) Metrics() Metrics {
+	st := p.Stats()
+	return Metrics{
+		N:      st.N(),
+		Mean:   st.Mean(),
+		StdDev: st.StdDev(),
+		Gets:   p.gets.Load(),
+		Puts:   p.puts.Load(),
+		Drops:  p.drops.Load(),
+		Misses: p.misses.Load(),
+	}
+}
+
+// Gets returns the number of calls to Get and GetWait.
+func (p *AdaptivePool[T]) Gets() uint64 { return p.gets.Load() }
+
+// Misses returns the number of items created because the pool had none
+// available. Together with Gets, it allows computing the hit ratio of the
+// pool.
+func (p *AdaptivePool[T]) Misses() uint64 { return p.misses.Load() }
+
+// PublishExpvar publishes the Metrics of the pool with [expvar.Publish] under
+// the given name, as a JSON object with the fields "n", "mean", "stdDev",
+// "gets", "puts", "drops" and "misses". Undefined values, like the StdDev with
+// less than two values, are published as null. Like expvar.Publish, it panics
+// if the name is already in use.
+func (p *AdaptivePool[T]) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		m := p.Metrics()
+		return map[string]any{
+			"n":      jsonFloat(m.N),
+			"mean":   jsonFloat(m.Mean),
+			"stdDev": jsonFloat(m.StdDev),
+			"gets":   m.Gets,
+			"puts":   m.Puts,
+			"drops":  m.Drops,
+			"misses": m.Misses,
+		}
+	}))
+}
+
+// jsonFloat returns nil for values that can't be encoded as JSON numbers.
+func jsonFloat(v float64) any {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
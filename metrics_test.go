@@ -0,0 +1,89 @@
+package adaptivepool
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestAdaptivePoolPublishExpvar(t *testing.T) {
+	t.Parallel()
+	const name = "TestAdaptivePoolPublishExpvar"
+
+	ap, _ := newStackAdaptivePool[[]int](NormalSlice[int]{Threshold: 1}, 0)
+	ap.PublishExpvar(name)
+	read := func() map[string]any {
+		t.Helper()
+		v := expvar.Get(name)
+		equal(t, true, v != nil, "should have been published")
+		var m map[string]any
+		zero(t, json.Unmarshal([]byte(v.String()), &m), "decode JSON")
+		return m
+	}
+
+	m := read()
+	equal[any](t, nil, m["stdDev"], "undefined StdDev should be null")
+	equal[any](t, 0.0, m["gets"], "gets of new pool")
+
+	ap.Get()                                  // miss
+	for _, v := range []int{10, 10, 10, 20} { // the last one is dropped
+		ap.Put(make([]int, v))
+	}
+	ap.Get()    // hit
+	ap.Put(nil) // dropped
+	ap.PutForce(make([]int, 100))
+
+	m = read()
+	for k, want := range map[string]float64{
+		"n":      5,
+		"mean":   30,
+		"gets":   2,
+		"puts":   6,
+		"drops":  2,
+		"misses": 1,
+	} {
+		equal[any](t, want, m[k], "%s", k)
+	}
+	equal(t, true, m["stdDev"] != nil, "StdDev should be defined")
+
+	want := Metrics{
+		N:      5,
+		Mean:   30,
+		Gets:   2,
+		Puts:   6,
+		Drops:  2,
+		Misses: 1,
+	}
+	got := ap.Metrics()
+	want.StdDev = got.StdDev
+	equal(t, want, got, "Metrics")
+}
+
+func TestAdaptivePoolMisses(t *testing.T) {
+	t.Parallel()
+
+	ap := New[[]int](NormalSlice[int]{Threshold: 1}, 0)
+	ap.Get()
+	equal(t, 1, ap.Gets(), "Gets")
+	equal(t, 1, ap.Misses(), "Get from an empty sync.Pool should be a miss")
+
+	// sync.Pool may drop items at any time, in particular with the race
+	// detector, so retry until we get a hit
+	gets, misses := uint64(1), uint64(1)
+	var hit bool
+	for i := 0; i < 100 && !hit; i++ {
+		ap.Put(make([]int, 5))
+		hit = len(ap.Get()) == 5
+		gets++
+		if !hit {
+			misses++
+		}
+	}
+	equal(t, true, hit, "should have reused an item")
+	equal(t, gets, ap.Gets(), "Gets after reusing an item")
+	equal(t, misses, ap.Misses(), "Misses after reusing an item")
+
+	ap.Get()
+	equal(t, gets+1, ap.Gets(), "Gets")
+	equal(t, misses+1, ap.Misses(), "Get from an empty sync.Pool")
+}
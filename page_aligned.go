@@ -0,0 +1,74 @@
+package adaptivepool
+
+import (
+	"os"
+	"unsafe"
+)
+
+var pageSize = os.Getpagesize()
+
+// PageAlignedSlice is a [PoolItemProvider] for []byte items whose backing array
+// starts at a memory page boundary, as needed for example for direct I/O. It
+// operates under the same assumptions as [NormalSlice]. To guarantee the
+// alignment, Create allocates up to one extra memory page that is never used,
+// so this overhead should be considered for small sizes. Slices whose backing
+// array is not aligned (e.g. because they were grown by `append`) are not put
+// back into the pool. Created slices have length zero and their capacity is
+// limited to the computed size, so appending beyond it will reallocate.
+type PageAlignedSlice struct {
+	MinCap    int     // Minimum capacity of a newly created slice
+	Threshold float64 // Threshold must be non-negative.
+}
+
+// Sizeof returns the length of the slice, or -1 if the slice has zero capacity
+// or is not page-aligned.
+func (p PageAlignedSlice) Sizeof(v []byte) float64 {
+	if cap(v) == 0 || !isPageAligned(v) {
+		return -1
+	}
+	return float64(len(v))
+}
+
+// Create returns a new page-aligned slice with length zero and cap `mean +
+// Threshold * stdDev`, or `mean` if `stdDev` is `NaN`. It returns nil if the
+// computed capacity is zero.
+func (p PageAlignedSlice) Create(mean, stdDev float64) []byte {
+	size := int(p.CreateSize(mean, stdDev))
+	if size == 0 {
+		return nil
+	}
+	buf := make([]byte, size+pageSize-1)
+	off := pageAlignOffset(buf)
+	return buf[off : off : off+size]
+}
+
+// CreateSize returns the capacity of the slices returned by Create, which is
+// never less than MinCap.
+func (p PageAlignedSlice) CreateSize(mean, stdDev float64) float64 {
+	size := int(normalCreateSize(mean, stdDev, p.Threshold))
+	return float64(max(size, p.MinCap))
+}
+
+// Accept will accept a new item if its length is in the inclusive range
+// `mean ± Threshold * stdDev`, or if `stdDev` is `NaN`.
+func (p PageAlignedSlice) Accept(mean, stdDev, itemSize float64) bool {
+	return normalAccept(mean, stdDev, p.Threshold, itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p PageAlignedSlice) Validate() error {
+	return validateThresholds("PageAlignedSlice", p.Threshold)
+}
+
+func isPageAligned(v []byte) bool {
+	return pageAlignOffset(v[:cap(v)]) == 0
+}
+
+// pageAlignOffset returns the index of the first element of `v` that is at a
+// page boundary, assuming `v` is large enough.
+func pageAlignOffset(v []byte) int {
+	addr := uintptr(unsafe.Pointer(unsafe.SliceData(v)))
+	return int((uintptr(pageSize) - addr%uintptr(pageSize)) %
+		uintptr(pageSize))
+}
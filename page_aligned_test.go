@@ -0,0 +1,54 @@
+package adaptivepool
+
+import (
+	"math"
+	"testing"
+)
+
+var _ PoolItemProvider[[]byte] = PageAlignedSlice{}
+
+func TestPageAlignedSlice(t *testing.T) {
+	t.Parallel()
+
+	p := PageAlignedSlice{
+		MinCap:    10,
+		Threshold: 1,
+	}
+
+	testCases := []struct {
+		mean, stdDev float64
+		expectedCap  int
+	}{
+		{0, math.NaN(), 10},
+		{100, math.NaN(), 100},
+		{100, 50, 150},
+		{5000, 1000, 6000},
+		{1 << 20, 0, 1 << 20},
+	}
+	for i, tc := range testCases {
+		v := p.Create(tc.mean, tc.stdDev)
+		equal(t, tc.expectedCap, cap(v), "[#%d] cap", i)
+		zero(t, len(v), "[#%d] len", i)
+		equal(t, true, isPageAligned(v), "[#%d] should be page-aligned", i)
+		equal(t, float64(cap(v)), p.CreateSize(tc.mean, tc.stdDev),
+			"[#%d] CreateSize", i)
+
+		v = append(v, 1, 2, 3)
+		equal(t, 3, p.Sizeof(v), "[#%d] Sizeof", i)
+	}
+
+	zero(t, cap(PageAlignedSlice{}.Create(0, math.NaN())),
+		"zero size should not allocate")
+	equal(t, -1, p.Sizeof(nil), "Sizeof(nil)")
+
+	v := p.Create(100, 0)
+	unaligned := v[1:cap(v)]
+	equal(t, -1, p.Sizeof(unaligned), "Sizeof of unaligned slice")
+
+	ap, sp := newStackAdaptivePool[[]byte](p, 0)
+	ap.Put(unaligned)
+	zero(t, sp.Len(), "unaligned slices should be dropped")
+	ap.Put(v)
+	equal(t, 1, sp.Len(), "aligned slices should be retained")
+	equal(t, true, isPageAligned(ap.Get()), "should be page-aligned")
+}
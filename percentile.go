@@ -0,0 +1,287 @@
+package adaptivepool
+
+import (
+	"math"
+	"slices"
+	"sync"
+)
+
+// Percentile is a streaming estimator of a percentile using the P² algorithm by
+// Jain and Chlamtac, which uses constant memory and doesn't need to store the
+// observations. Unlike the Mean and StdDev in [Stats], the estimation does not
+// assume any distribution, which makes it suitable for skewed data. It is not
+// safe for concurrent use.
+type Percentile struct {
+	p       float64
+	count   int
+	heights [5]float64
+	pos     [5]float64 // actual positions of the markers, 1-based
+	desired [5]float64 // desired positions of the markers, 1-based
+}
+
+// NewPercentile returns a Percentile estimating the `p`-quantile, which must be
+// in the range (0, 1). For example, 0.9 estimates the 90th percentile.
+func NewPercentile(p float64) *Percentile {
+	return &Percentile{p: p}
+}
+
+// P returns the quantile being estimated.
+func (e *Percentile) P() float64 { return e.p }
+
+// N returns the number of values pushed.
+func (e *Percentile) N() int { return e.count }
+
+// Push adds a new value to the estimation.
+func (e *Percentile) Push(v float64) {
+	if e.count < len(e.heights) {
+		e.heights[e.count] = v
+		e.count++
+		if e.count == len(e.heights) {
+			slices.Sort(e.heights[:])
+			p := e.p
+			e.pos = [5]float64{1, 2, 3, 4, 5}
+			e.desired = [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5}
+		}
+		return
+	}
+	e.count++
+
+	// find the cell of the new value, updating the extreme markers if needed
+	var k int
+	switch {
+	case v < e.heights[0]:
+		e.heights[0] = v
+	case v >= e.heights[4]:
+		e.heights[4] = v
+		k = 3
+	default:
+		for k = 0; k < 3 && v >= e.heights[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < len(e.pos); i++ {
+		e.pos[i]++
+	}
+	p := e.p
+	e.desired[1] += p / 2
+	e.desired[2] += p
+	e.desired[3] += (1 + p) / 2
+	e.desired[4]++
+
+	// adjust the heights of the middle markers
+	for i := 1; i < 4; i++ {
+		d := e.desired[i] - e.pos[i]
+		if d >= 1 && e.pos[i+1]-e.pos[i] > 1 ||
+			d <= -1 && e.pos[i-1]-e.pos[i] < -1 {
+			d = math.Copysign(1, d)
+			h := e.parabolic(i, d)
+			if h <= e.heights[i-1] || h >= e.heights[i+1] {
+				h = e.linear(i, d)
+			}
+			e.heights[i] = h
+			e.pos[i] += d
+		}
+	}
+}
+
+func (e *Percentile) parabolic(i int, d float64) float64 {
+	q, n := &e.heights, &e.pos
+	return q[i] + d/(n[i+1]-n[i-1])*((n[i]-n[i-1]+d)*(q[i+1]-q[i])/
+		(n[i+1]-n[i])+(n[i+1]-n[i]-d)*(q[i]-q[i-1])/(n[i]-n[i-1]))
+}
+
+func (e *Percentile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return e.heights[i] + d*(e.heights[j]-e.heights[i])/(e.pos[j]-e.pos[i])
+}
+
+// Value returns the estimated percentile. With less than five values, it is the
+// nearest-rank percentile of the values pushed. It returns NaN if no values
+// were pushed.
+func (e *Percentile) Value() float64 {
+	if e.count == 0 {
+		return math.NaN()
+	}
+	if e.count < len(e.heights) {
+		sorted := e.heights
+		slices.Sort(sorted[:e.count])
+		i := int(math.Ceil(e.p*float64(e.count))) - 1
+		return sorted[min(max(i, 0), e.count-1)]
+	}
+	return e.heights[2]
+}
+
+// PercentileSlice is a generic [PoolItemProvider] for slice items that makes no
+// assumption about the distribution of their `len`, which makes it more
+// suitable than [NormalSlice] for heavily skewed sizes. It estimates the
+// percentile P of the sizes of the items measured with Sizeof, creates items
+// with that capacity and accepts items whose length is at most that. The
+// `mean` and `stdDev` arguments are ignored. It holds the state of the
+// estimation, so it must be created with [NewPercentileSlice] and it should
+// not be shared by multiple [AdaptivePool]s. It is safe for concurrent use.
+type PercentileSlice[T any] struct {
+	MinCap int // Minimum capacity of a newly created slice
+
+	mu  sync.Mutex
+	est *Percentile
+}
+
+// NewPercentileSlice returns a new PercentileSlice for the given percentile,
+// which must be in the range (0, 1).
+func NewPercentileSlice[T any](p float64, minCap int) *PercentileSlice[T] {
+	return &PercentileSlice[T]{
+		MinCap: minCap,
+		est:    NewPercentile(p),
+	}
+}
+
+// Percentile returns the current estimation of the percentile, or NaN if no
+// items were measured yet.
+func (p *PercentileSlice[T]) Percentile() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.est.Value()
+}
+
+// Sizeof returns the length of the slice, and adds it to the estimation.
+func (p *PercentileSlice[T]) Sizeof(v []T) float64 {
+	if cap(v) == 0 {
+		return -1
+	}
+	size := float64(len(v))
+	p.mu.Lock()
+	p.est.Push(size)
+	p.mu.Unlock()
+	return size
+}
+
+// Create returns a new slice with length zero and cap the estimated
+// percentile, or MinCap if greater.
+func (p *PercentileSlice[T]) Create(mean, stdDev float64) []T {
+	return make([]T, 0, int(p.CreateSize(mean, stdDev)))
+}
+
+// CreateSize returns the capacity of the slices returned by Create.
+func (p *PercentileSlice[T]) CreateSize(mean, stdDev float64) float64 {
+	var size int
+	if v := p.Percentile(); !math.IsNaN(v) {
+		size = int(math.Ceil(v))
+	}
+	return float64(max(size, p.MinCap))
+}
+
+// Accept will accept a new item if its length is at most the estimated
+// percentile, or if there is no estimation yet.
+func (p *PercentileSlice[T]) Accept(mean, stdDev, itemSize float64) bool {
+	v := p.Percentile()
+	return math.IsNaN(v) || itemSize <= v
+}
+
+// MAD is a streaming estimator of the median and the Median Absolute Deviation
+// (MAD), a measure of spread that, unlike the Standard Deviation, is robust to
+// outliers. Both are estimated with [Percentile], and each value deviates from
+// the median estimated at the time it's pushed, so the MAD is approximate
+// until the median converges. For normally distributed data, the MAD is about
+// 0.6745 times the Standard Deviation. It is not safe for concurrent use.
+type MAD struct {
+	median Percentile
+	dev    Percentile
+}
+
+// NewMAD returns a new MAD.
+func NewMAD() *MAD {
+	return &MAD{
+		median: Percentile{p: 0.5},
+		dev:    Percentile{p: 0.5},
+	}
+}
+
+// N returns the number of values pushed.
+func (e *MAD) N() int { return e.median.N() }
+
+// Push adds a new value to the estimation.
+func (e *MAD) Push(v float64) {
+	e.median.Push(v)
+	e.dev.Push(math.Abs(v - e.median.Value()))
+}
+
+// Median returns the estimated median, or NaN if no values were pushed.
+func (e *MAD) Median() float64 { return e.median.Value() }
+
+// Deviation returns the estimated MAD, or NaN if no values were pushed.
+func (e *MAD) Deviation() float64 { return e.dev.Value() }
+
+// RobustNormalSlice is a generic [PoolItemProvider] for slice items similar to
+// [NormalSlice], but it uses the median and the MAD of their `len` instead of
+// the Mean and Standard Deviation, so that occasional huge items don't inflate
+// the size of created items for a long time. It estimates them from the sizes
+// of the items measured with Sizeof, and the `mean` and `stdDev` arguments are
+// ignored. It holds the state of the estimation, so it must be created with
+// [NewRobustNormalSlice] and it should not be shared by multiple
+// [AdaptivePool]s. It is safe for concurrent use.
+type RobustNormalSlice[T any] struct {
+	MinCap    int     // Minimum capacity of a newly created slice
+	Threshold float64 // Threshold must be non-negative.
+
+	mu  sync.Mutex
+	est *MAD
+}
+
+// NewRobustNormalSlice returns a new RobustNormalSlice.
+func NewRobustNormalSlice[T any](
+	minCap int,
+	thresh float64,
+) *RobustNormalSlice[T] {
+	return &RobustNormalSlice[T]{
+		MinCap:    minCap,
+		Threshold: thresh,
+		est:       NewMAD(),
+	}
+}
+
+// Estimates returns the current estimations of the median and the MAD, which
+// are NaN if no items were measured yet.
+func (p *RobustNormalSlice[T]) Estimates() (median, mad float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.est.Median(), p.est.Deviation()
+}
+
+// Sizeof returns the length of the slice, and adds it to the estimation.
+func (p *RobustNormalSlice[T]) Sizeof(v []T) float64 {
+	if cap(v) == 0 {
+		return -1
+	}
+	size := float64(len(v))
+	p.mu.Lock()
+	p.est.Push(size)
+	p.mu.Unlock()
+	return size
+}
+
+// Create returns a new slice with length zero and cap `median + Threshold *
+// MAD`, or MinCap if greater.
+func (p *RobustNormalSlice[T]) Create(mean, stdDev float64) []T {
+	return make([]T, 0, int(p.CreateSize(mean, stdDev)))
+}
+
+// CreateSize returns the capacity of the slices returned by Create.
+func (p *RobustNormalSlice[T]) CreateSize(mean, stdDev float64) float64 {
+	var size int
+	if median, mad := p.Estimates(); !math.IsNaN(median) {
+		size = int(math.Ceil(normalCreateSize(median, mad, p.Threshold)))
+	}
+	return float64(max(size, p.MinCap))
+}
+
+// Accept will accept a new item if its length is in the inclusive range
+// `median ± Threshold * MAD`, or if there is no estimation yet.
+func (p *RobustNormalSlice[T]) Accept(mean, stdDev, itemSize float64) bool {
+	median, mad := p.Estimates()
+	return normalAccept(median, mad, p.Threshold, itemSize)
+}
+
+// Validate returns an error wrapping ErrInvalidThreshold if Threshold is
+// negative or NaN.
+func (p *RobustNormalSlice[T]) Validate() error {
+	return validateThresholds("RobustNormalSlice", p.Threshold)
+}
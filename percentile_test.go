@@ -0,0 +1,171 @@
+package adaptivepool
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+var (
+	_ PoolItemProvider[[]byte] = (*PercentileSlice[byte])(nil)
+	_ CreateSizer              = (*PercentileSlice[byte])(nil)
+	_ PoolItemProvider[[]byte] = (*RobustNormalSlice[byte])(nil)
+	_ CreateSizer              = (*RobustNormalSlice[byte])(nil)
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	e := NewPercentile(0.5)
+	equal(t, true, math.IsNaN(e.Value()), "zero value")
+	for i, tc := range []struct{ v, expected float64 }{
+		{30, 30},
+		{10, 10},
+		{20, 20},
+	} {
+		e.Push(tc.v)
+		equal(t, tc.expected, e.Value(), "[#%d] nearest-rank percentile", i)
+	}
+	equal(t, 3, e.N(), "N")
+	equal(t, 0.5, e.P(), "P")
+
+	values := allTestDataInputValues(t)
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	for _, p := range []float64{0.1, 0.5, 0.9, 0.95, 0.99} {
+		e := NewPercentile(p)
+		for _, v := range values {
+			e.Push(v)
+		}
+		want := sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+		if relErr := math.Abs(e.Value()-want) / want; relErr > 0.03 {
+			t.Fatalf("p=%v: estimation too far from the actual percentile; "+
+				"want: %v, got: %v, relative error: %v", p, want, e.Value(),
+				relErr)
+		}
+	}
+}
+
+func TestPercentileSlice(t *testing.T) {
+	t.Parallel()
+
+	provider := NewPercentileSlice[byte](0.9, 8)
+	ap, sp := newStackAdaptivePool[[]byte](provider, 0)
+	equal(t, 8, cap(ap.Get()), "MinCap should be used without estimation")
+	ap.Put(nil) // should be a nop
+	equal(t, true, math.IsNaN(provider.Percentile()), "no estimation yet")
+
+	// heavily skewed sizes: mostly tiny items, with occasional huge ones
+	for i := 0; i < 1000; i++ {
+		size := 10 + i%10
+		if i%50 == 0 {
+			size = 100_000
+		}
+		ap.Put(make([]byte, size))
+	}
+	pct := provider.Percentile()
+	if pct < 17 || pct > 19 {
+		t.Fatalf("unexpected 90th percentile estimation: %v", pct)
+	}
+	equal(t, math.Ceil(pct), ap.CreateSize(), "CreateSize")
+	equal(t, false, provider.Accept(0, 0, 100_000), "huge items rejected")
+	equal(t, true, provider.Accept(0, 0, 10), "tiny items accepted")
+
+	var huge int
+	for sp.Len() > 0 {
+		if len(ap.Get()) == 100_000 {
+			huge++
+		}
+	}
+	equal(t, 1, huge, "only the first huge item should have been retained, "+
+		"before there was an estimation")
+}
+
+func TestMAD(t *testing.T) {
+	t.Parallel()
+
+	e := NewMAD()
+	equal(t, true, math.IsNaN(e.Median()), "zero value Median")
+	equal(t, true, math.IsNaN(e.Deviation()), "zero value Deviation")
+
+	values := allTestDataInputValues(t)
+	for _, v := range values {
+		e.Push(v)
+	}
+	equal(t, len(values), e.N(), "N")
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	devs := make([]float64, len(sorted))
+	for i, v := range sorted {
+		devs[i] = math.Abs(v - median)
+	}
+	slices.Sort(devs)
+	mad := devs[len(devs)/2]
+
+	if relErr := math.Abs(e.Median()-median) / median; relErr > 0.03 {
+		t.Fatalf("median estimation too far; want: %v, got: %v", median,
+			e.Median())
+	}
+	if relErr := math.Abs(e.Deviation()-mad) / mad; relErr > 0.05 {
+		t.Fatalf("MAD estimation too far; want: %v, got: %v", mad,
+			e.Deviation())
+	}
+}
+
+func TestRobustNormalSliceOutliers(t *testing.T) {
+	t.Parallel()
+
+	// compare how much the upper bound of the band used to create and accept
+	// items grows after injecting outliers, using MAD and StdDev
+	const thresh = 2
+	values := allTestDataInputValues(t)
+	var st Stats
+	provider := NewRobustNormalSlice[byte](0, thresh)
+	for _, v := range values {
+		st.Push(v)
+		provider.Sizeof(make([]byte, int(v)))
+	}
+	normalBefore := normalCreateSize(st.Mean(), st.StdDev(), thresh)
+	robustBefore := provider.CreateSize(0, 0)
+
+	outlier := 100 * slices.Max(values)
+	for i := 0; i < len(values)/100; i++ {
+		st.Push(outlier)
+		provider.Sizeof(make([]byte, int(outlier)))
+	}
+	normalGrowth := normalCreateSize(st.Mean(), st.StdDev(), thresh) /
+		normalBefore
+	robustGrowth := provider.CreateSize(0, 0) / robustBefore
+
+	if robustGrowth > 1.1 || robustGrowth >= normalGrowth {
+		t.Fatalf("robust band should be stable with outliers; growth of the "+
+			"robust band: %v, growth of the normal band: %v", robustGrowth,
+			normalGrowth)
+	}
+	equal(t, false, provider.Accept(0, 0, outlier), "outliers rejected")
+}
+
+func TestRobustNormalSlice(t *testing.T) {
+	t.Parallel()
+
+	provider := NewRobustNormalSlice[int](8, 1)
+	ap, _ := newStackAdaptivePool[[]int](provider, 0)
+	equal(t, 8, cap(ap.Get()), "MinCap should be used without estimation")
+	equal(t, true, provider.Accept(0, 0, 1e6), "no estimation yet")
+	ap.Put(nil) // should be a nop
+	median, mad := provider.Estimates()
+	equal(t, true, math.IsNaN(median), "no median yet")
+	equal(t, true, math.IsNaN(mad), "no MAD yet")
+
+	for _, size := range []int{10, 20, 30, 40, 50} {
+		ap.Put(make([]int, size))
+	}
+	median, mad = provider.Estimates()
+	equal(t, 30, median, "median")
+	equal(t, 10, mad, "MAD")
+	equal(t, 40, ap.CreateSize(), "CreateSize")
+	equal(t, true, provider.Accept(0, 0, 20), "within the band")
+	equal(t, false, provider.Accept(0, 0, 41), "outside the band")
+}
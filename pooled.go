@@ -0,0 +1,47 @@
+package adaptivepool
+
+// Pooled is a handle to an item obtained with [AdaptivePool.GetHandle], which
+// makes it easier to Put the item back exactly once, e.g. with `defer`. It is
+// not safe for concurrent use.
+type Pooled[T any] struct {
+	pool  *AdaptivePool[T]
+	value T
+}
+
+// GetHandle is like Get, but it returns the item wrapped in a Pooled handle.
+// Example:
+//
+//	h := pool.GetHandle()
+//	defer h.Release()
+//	buf := h.Value()
+func (p *AdaptivePool[T]) GetHandle() *Pooled[T] {
+	return &Pooled[T]{
+		pool:  p,
+		value: p.Get(),
+	}
+}
+
+// Value returns the item, or the zero value after calling Release. If the item
+// is modified by value, like appending to a slice, then the result should be
+// stored with Set before calling Release, so that its final size is measured.
+func (h *Pooled[T]) Value() T {
+	return h.value
+}
+
+// Set replaces the item that will be put back by Release. It is a no-op after
+// calling Release.
+func (h *Pooled[T]) Set(x T) {
+	if h.pool != nil {
+		h.value = x
+	}
+}
+
+// Release puts the item back into the pool with [AdaptivePool.Put], updating
+// the statistics. After this, the handle will be empty, and subsequent calls
+// to Release are a no-op.
+func (h *Pooled[T]) Release() {
+	if h.pool != nil {
+		h.pool.Put(h.value)
+		*h = Pooled[T]{}
+	}
+}
@@ -0,0 +1,34 @@
+package adaptivepool
+
+import "testing"
+
+func TestPooled(t *testing.T) {
+	t.Parallel()
+
+	ap, sp := newStackAdaptivePool[[]byte](NormalSlice[byte]{
+		MinCap:    8,
+		Threshold: 2,
+	}, 500)
+	h := ap.GetHandle()
+	v := h.Value()
+	equal(t, 8, cap(v), "should get a new item")
+	h.Set(append(v, "hello"...))
+
+	h.Release()
+	st := ap.Stats()
+	equal(t, 1, st.N(), "Release should update the statistics")
+	equal(t, 5, st.Mean(), "should measure the item set")
+	equal(t, 1, sp.Len(), "Release should put the item back")
+	zero(t, h.Value(), "Value after Release")
+
+	h.Release()
+	h.Set([]byte("ignored"))
+	h.Release()
+	st = ap.Stats()
+	equal(t, 1, st.N(), "second Release should be a no-op")
+	equal(t, 1, sp.Len(), "second Release should be a no-op")
+
+	h = ap.GetHandle()
+	equal(t, "hello", string(h.Value()), "should reuse the item")
+	zero(t, sp.Len(), "should take the item from the pool")
+}
@@ -0,0 +1,81 @@
+package adaptivepool
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SizeUnit is the unit of the sizes measured by a [PoolItemProvider].
+type SizeUnit int
+
+// Supported size units.
+const (
+	UnitNone  SizeUnit = iota // Sizes have no specific unit.
+	UnitBytes                 // Sizes are a number of bytes.
+)
+
+// SizeUnitHinter is an optional interface that a [PoolItemProvider] can
+// implement to report the unit of the sizes it measures. It is used to produce
+// human-friendly reports.
+type SizeUnitHinter interface {
+	SizeUnit() SizeUnit
+}
+
+// SizeUnit returns UnitBytes for slices of bytes, and UnitNone otherwise.
+func (p NormalSlice[T]) SizeUnit() SizeUnit {
+	var zero T
+	if _, ok := any(zero).(byte); ok {
+		return UnitBytes
+	}
+	return UnitNone
+}
+
+// SizeUnit returns UnitBytes.
+func (p NormalBytesBuffer) SizeUnit() SizeUnit { return UnitBytes }
+
+// SizeUnit returns UnitBytes.
+func (p NormalStringsBuilder) SizeUnit() SizeUnit { return UnitBytes }
+
+// SizeUnit returns UnitBytes.
+func (p PageAlignedSlice) SizeUnit() SizeUnit { return UnitBytes }
+
+// ByteSize is a number of bytes. It is formatted using binary prefixes with at
+// most one decimal, e.g. "1.5 MiB".
+type ByteSize float64
+
+var byteSizeUnits = [...]string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// String is part of the implementation of the fmt.Stringer interface.
+func (b ByteSize) String() string {
+	v := float64(b)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return formatDecimal(v)
+	}
+	var i int
+	for math.Abs(v) >= 1024 && i < len(byteSizeUnits)-1 {
+		v /= 1024
+		i++
+	}
+	return formatDecimal(v) + " " + byteSizeUnits[i]
+}
+
+// formatDecimal formats `v` with at most one decimal.
+func formatDecimal(v float64) string {
+	return strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0")
+}
+
+// Report returns a human-readable summary of the statistics of the pool, e.g.
+// "n=500 mean=1.5 MiB stdDev=128 KiB maxN=500". If the PoolItemProvider
+// implements [SizeUnitHinter], then sizes are formatted accordingly.
+func (p *AdaptivePool[T]) Report() string {
+	st := p.Stats()
+	formatSize := formatDecimal
+	if h, ok := p.provider.(SizeUnitHinter); ok && h.SizeUnit() == UnitBytes {
+		formatSize = func(v float64) string { return ByteSize(v).String() }
+	}
+	return "n=" + formatDecimal(st.N()) +
+		" mean=" + formatSize(st.Mean()) +
+		" stdDev=" + formatSize(st.StdDev()) +
+		" maxN=" + formatDecimal(st.MaxN())
+}
@@ -0,0 +1,69 @@
+package adaptivepool
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+var (
+	_ SizeUnitHinter = NormalSlice[byte]{}
+	_ SizeUnitHinter = NormalSlicePtr[byte]{}
+	_ SizeUnitHinter = NormalBytesBuffer{}
+	_ SizeUnitHinter = PageAlignedSlice{}
+)
+
+func TestByteSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		size     ByteSize
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{15.25, "15.2 B"},
+		{1024, "1 KiB"},
+		{1536, "1.5 KiB"},
+		{1572864, "1.5 MiB"},
+		{5 << 30, "5 GiB"},
+		{-2048, "-2 KiB"},
+		{1 << 70, "1024 EiB"},
+		{ByteSize(math.NaN()), "NaN"},
+		{ByteSize(math.Inf(1)), "+Inf"},
+	}
+
+	for i, tc := range testCases {
+		equal(t, tc.expected, tc.size.String(), "[#%d] formatted size", i)
+	}
+}
+
+func TestSizeUnit(t *testing.T) {
+	t.Parallel()
+
+	equal(t, UnitBytes, NormalSlice[byte]{}.SizeUnit(), "[]byte")
+	equal(t, UnitBytes, NormalSlice[uint8]{}.SizeUnit(), "[]uint8")
+	equal(t, UnitNone, NormalSlice[int8]{}.SizeUnit(), "[]int8")
+	equal(t, UnitNone, NormalSlice[string]{}.SizeUnit(), "[]string")
+	equal(t, UnitBytes, NormalBytesBuffer{}.SizeUnit(), "*bytes.Buffer")
+}
+
+func TestAdaptivePoolReport(t *testing.T) {
+	t.Parallel()
+
+	buffers := New[*bytes.Buffer](NormalBytesBuffer{}, 500)
+	equal(t, "n=0 mean=0 B stdDev=NaN maxN=500", buffers.Report(),
+		"empty pool report")
+	buffers.Put(bytes.NewBuffer(make([]byte, 1572864)))
+	equal(t, "n=1 mean=1.5 MiB stdDev=NaN maxN=500", buffers.Report(),
+		"report with one item")
+	buffers.Put(bytes.NewBuffer(make([]byte, 1572864+2048)))
+	equal(t, "n=2 mean=1.5 MiB stdDev=1 KiB maxN=500", buffers.Report(),
+		"report with two items")
+
+	ints := New[int](intProvider{}, 0)
+	ints.Put(10)
+	ints.Put(15)
+	equal(t, "n=2 mean=12.5 stdDev=2.5 maxN=0", ints.Report(),
+		"report without unit")
+}
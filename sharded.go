@@ -0,0 +1,68 @@
+package adaptivepool
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// ShardedPool is like an [AdaptivePool], but its statistics are split into
+// independent shards, each protected by a separate lock, reducing lock
+// contention in Put under high concurrency. Each call to Get or Put uses a
+// randomly chosen shard, so all the shards see a similar sample of the sizes.
+// All the shards share the same underlying [sync.Pool], so items put through a
+// shard can be reused by any other.
+type ShardedPool[T any] struct {
+	shards []*AdaptivePool[T]
+}
+
+// NewSharded creates a ShardedPool with the given number of shards, or one if
+// `shards` is less than one. Each shard has a MaxN of `maxN / shards`, rounded
+// up, so that the statistics adapt at the same pace as those of an
+// AdaptivePool with the same `maxN`. See [Stats.SetMaxN] for a description of
+// the `maxN` argument.
+func NewSharded[T any](
+	p PoolItemProvider[T],
+	maxN float64,
+	shards int,
+) *ShardedPool[T] {
+	shards = max(shards, 1)
+	shardMaxN := maxN
+	if maxN >= 1 {
+		shardMaxN = math.Ceil(maxN / float64(shards))
+	}
+	sp := &ShardedPool[T]{
+		shards: make([]*AdaptivePool[T], shards),
+	}
+	for i := range sp.shards {
+		sp.shards[i] = New(p, shardMaxN)
+		if i > 0 {
+			sp.shards[i].setPool(sp.shards[0].loadPool())
+		}
+	}
+	return sp
+}
+
+func (sp *ShardedPool[T]) shard() *AdaptivePool[T] {
+	if len(sp.shards) == 1 {
+		return sp.shards[0]
+	}
+	return sp.shards[rand.IntN(len(sp.shards))]
+}
+
+// Get is the same as [AdaptivePool.Get], creating items with the statistics of
+// a random shard.
+func (sp *ShardedPool[T]) Get() T {
+	return sp.shard().Get()
+}
+
+// Put is the same as [AdaptivePool.Put], updating the statistics of a random
+// shard.
+func (sp *ShardedPool[T]) Put(x T) {
+	sp.shard().Put(x)
+}
+
+// Stats returns the statistics of all the shards merged with
+// [AggregateStats].
+func (sp *ShardedPool[T]) Stats() Stats {
+	return AggregateStats(sp.shards...)
+}
@@ -0,0 +1,66 @@
+package adaptivepool
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestShardedPool(t *testing.T) {
+	t.Parallel()
+
+	sp := NewSharded[int](intProvider{}, 100, 4)
+	equal(t, 4, len(sp.shards), "number of shards")
+	for i, shard := range sp.shards {
+		st := shard.Stats()
+		equal(t, 25, st.MaxN(), "[#%d] shard MaxN", i)
+	}
+	st := sp.Stats()
+	equal(t, 100, st.MaxN(), "merged MaxN")
+
+	// without MaxN, merging should be the same as a single Stats
+	sp = NewSharded[int](intProvider{}, 0, 4)
+	stack := new(stackPool)
+	for _, shard := range sp.shards {
+		shard.setPool(stack)
+	}
+
+	values := allTestDataInputValues(t)[:1000]
+	var single Stats
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := i; j < len(values); j += 4 {
+				sp.Put(int(values[j]))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, v := range values {
+		single.Push(float64(int(v)))
+	}
+
+	st = sp.Stats()
+	equal(t, single.N(), st.N(), "merged N")
+	if relErr := math.Abs(st.Mean()-single.Mean()) / single.Mean(); relErr >
+		1e-12 {
+		t.Fatalf("merged Mean: want %v, got %v", single.Mean(), st.Mean())
+	}
+	if relErr := math.Abs(st.StdDev()-single.StdDev()) / single.StdDev(); relErr >
+		1e-9 {
+		t.Fatalf("merged StdDev: want %v, got %v", single.StdDev(),
+			st.StdDev())
+	}
+	equal(t, len(values), stack.Len(), "items should be in the shared pool")
+	for range values {
+		sp.Get()
+	}
+	equal(t, 0, stack.Len(), "items should be reused from any shard")
+
+	one := NewSharded[int](intProvider{}, 0, 0)
+	equal(t, 1, len(one.shards), "should have at least one shard")
+	one.Put(1)
+	equal(t, 1, one.Get(), "single shard")
+}
@@ -0,0 +1,95 @@
+package adaptivepool
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bucket is a range of sizes in a histogram, with the number of observed sizes
+// in that range.
+type Bucket struct {
+	Min, Max int // inclusive bounds of the sizes
+	Count    uint64
+}
+
+const (
+	sizeHistoryWindow = time.Minute
+	sizeHistorySlots  = 6
+	sizeHistorySlot   = sizeHistoryWindow / sizeHistorySlots
+)
+
+// sizeHistory is a histogram of the sizes observed during the last minute,
+// kept as a ring of time slots, each with its own counts, so that old sizes
+// are forgotten a slot at a time. Sizes are counted in buckets whose bounds
+// are powers of two. Sizes are only observed after the first call to buckets,
+// so that it costs nothing to the users that don't need it. It is safe for
+// concurrent use.
+type sizeHistory struct {
+	enabled atomic.Bool
+	mu      sync.Mutex
+	slots   [sizeHistorySlots]sizeSlot
+}
+
+type sizeSlot struct {
+	start  time.Time
+	counts [bits.UintSize + 1]uint64 // counts[i] has sizes of bit length i
+}
+
+// slot returns the slot for the time `now`, clearing it if it was last used
+// in a previous round of the ring.
+func (h *sizeHistory) slot(now time.Time) *sizeSlot {
+	start := now.Truncate(sizeHistorySlot)
+	i := start.UnixNano() / int64(sizeHistorySlot) % sizeHistorySlots
+	if i < 0 {
+		i += sizeHistorySlots // times before 1970
+	}
+	s := &h.slots[i]
+	if !s.start.Equal(start) {
+		*s = sizeSlot{start: start}
+	}
+	return s
+}
+
+// observe counts `size` at the current time of `clk`, if enabled.
+func (h *sizeHistory) observe(clk clock, size int) {
+	if !h.enabled.Load() {
+		return
+	}
+	now := clk.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.slot(now).counts[bits.Len(uint(max(size, 0)))]++
+}
+
+// buckets returns the non-empty buckets of the sizes observed in the window
+// ending at `now`, sorted by size, enabling observing sizes if it wasn't.
+func (h *sizeHistory) buckets(now time.Time) []Bucket {
+	h.enabled.Store(true)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var counts [bits.UintSize + 1]uint64
+	for i := range h.slots {
+		s := &h.slots[i]
+		if age := now.Sub(s.start); age >= 0 && age < sizeHistoryWindow {
+			for j, c := range s.counts {
+				counts[j] += c
+			}
+		}
+	}
+
+	var ret []Bucket
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		b := Bucket{Count: c}
+		if i > 0 {
+			b.Min = 1 << (i - 1)
+			b.Max = int(uint(1)<<i - 1)
+		}
+		ret = append(ret, b)
+	}
+	return ret
+}
@@ -0,0 +1,102 @@
+package adaptivepool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderBuffererSizeHistogram(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(8, 2, 500)
+	clk := newFakeClock()
+	brr.bufPool.clock = clk
+	zero(t, len(brr.SizeHistogram()), "no sizes observed")
+	assertBuckets := func(want []Bucket, msg string) {
+		t.Helper()
+		if got := brr.SizeHistogram(); !slices.Equal(want, got) {
+			t.Fatalf("%s: want %v, got %v", msg, want, got)
+		}
+	}
+
+	buffer := func(size int) {
+		t.Helper()
+		br, err := brr.Reader(strings.NewReader(strings.Repeat("x", size)))
+		zero(t, err, "Reader")
+		br.Close()
+	}
+	for _, size := range []int{0, 1, 5, 6, 7, 1000} {
+		buffer(size)
+	}
+	assertBuckets([]Bucket{
+		{0, 0, 1},
+		{1, 1, 1},
+		{4, 7, 3},
+		{512, 1023, 1},
+	}, "buckets")
+
+	clk.Advance(30 * time.Second)
+	buffer(4)
+	br, err := brr.Reader(strings.NewReader("not put back"))
+	zero(t, err, "Reader")
+	br.Bytes()
+	assertBuckets([]Bucket{
+		{0, 0, 1},
+		{1, 1, 1},
+		{4, 7, 4},
+		{512, 1023, 1},
+	}, "should count only the sizes put back")
+
+	clk.Advance(40 * time.Second)
+	assertBuckets([]Bucket{
+		{4, 7, 1},
+	}, "should forget sizes older than a minute")
+
+	clk.Advance(time.Minute)
+	assertBuckets(nil, "should forget all sizes")
+	buffer(2)
+	assertBuckets([]Bucket{
+		{2, 3, 1},
+	}, "should reuse the ring")
+}
+
+func TestReaderBuffererSizeHistogramOptIn(t *testing.T) {
+	t.Parallel()
+	brr := NewReaderBufferer(8, 2, 500)
+	clk := newFakeClock()
+	brr.bufPool.clock = clk
+
+	buffer := func(size int) {
+		t.Helper()
+		br, err := brr.Reader(strings.NewReader(strings.Repeat("x", size)))
+		zero(t, err, "Reader")
+		br.Close()
+	}
+	buffer(5)
+	zero(t, len(brr.SizeHistogram()),
+		"sizes should not be recorded before the first call")
+	buffer(5)
+	got := brr.SizeHistogram()
+	equal(t, 1, len(got), "buckets after the first call")
+	equal(t, Bucket{4, 7, 1}, got[0], "bucket after the first call")
+}
+
+func TestSizeHistoryBefore1970(t *testing.T) {
+	t.Parallel()
+	var h sizeHistory
+	clk := newFakeClock()
+	clk.now = time.Date(1969, 12, 31, 23, 59, 0, 0, time.UTC)
+	zero(t, len(h.buckets(clk.Now())), "no sizes observed")
+
+	for i := range sizeHistorySlots + 1 {
+		h.observe(clk, 5)
+		got := h.buckets(clk.Now())
+		want := uint64(min(i+1, sizeHistorySlots))
+		if len(got) != 1 || got[0] != (Bucket{4, 7, want}) {
+			t.Fatalf("[#%d] want one bucket with %d sizes, got %v", i, want,
+				got)
+		}
+		clk.Advance(sizeHistorySlot)
+	}
+}
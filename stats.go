@@ -1,6 +1,13 @@
 package adaptivepool
 
-import "math"
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
 
 // Stats efficiently computes a set of statistical values of numbers pushed to
 // it, with high precision, and without the need to store all the values.
@@ -8,16 +15,125 @@ type Stats struct {
 	n, actualN, maxN float64
 	oldM, newM       float64
 	oldS, newS       float64
+	winsorK          float64
+	smoothMaxN       float64 // 1 if enabled, float64 to simplify encoding
+	min, max         float64 // +Inf and -Inf if unknown, e.g. with a seed
+	decay            float64 // alpha of the EWMA mode, or zero
 }
 
-// Push adds a new value to the sample.
+// NewStatsSeed returns a Stats as if `n` values with the given Mean and
+// (Population) Standard Deviation had been pushed to it. The value of `stdDev`
+// is ignored if `n` is less than 2, and a zero value Stats is returned if `n`
+// is less than 1. Min and Max are unknown until a value is pushed. It is
+// mostly useful to warm start an AdaptivePool with [NewSeeded]. See also
+// [Stats.GoSeedExpr] and [AdaptivePool.Seed].
+func NewStatsSeed(n, mean, stdDev float64) Stats {
+	if n < 1 {
+		return Stats{}
+	}
+	s := Stats{
+		n:       n,
+		actualN: n,
+		oldM:    mean,
+		newM:    mean,
+		min:     math.Inf(1),
+		max:     math.Inf(-1),
+	}
+	if n > 1 && !math.IsNaN(stdDev) {
+		s.oldS = stdDev * stdDev * n
+		s.newS = s.oldS
+	}
+	return s
+}
+
+// GoSeedExpr returns a Go expression that calls [NewStatsSeed] with the current
+// values of N, Mean and StdDev. This allows turning the statistics learned
+// during a tuning run into reproducible configuration. Example output:
+//
+//	adaptivepool.NewStatsSeed(500, 1024.5, 128.25)
+func (s Stats) GoSeedExpr() string {
+	return "adaptivepool.NewStatsSeed(" + goFloatExpr(s.N()) + ", " +
+		goFloatExpr(s.Mean()) + ", " + goFloatExpr(s.StdDev()) + ")"
+}
+
+// String is part of the implementation of the fmt.Stringer interface. It
+// returns the main statistics for debugging, e.g.
+// "Stats{N:15 Mean:32 StdDev:16.5 MaxN:500}". Undefined values are formatted
+// as "NaN".
+func (s Stats) String() string {
+	return "Stats{N:" + formatFloat(s.N()) +
+		" Mean:" + formatFloat(s.Mean()) +
+		" StdDev:" + formatFloat(s.StdDev()) +
+		" MaxN:" + formatFloat(s.MaxN()) + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func goFloatExpr(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "math.NaN()"
+	case math.IsInf(v, 1):
+		return "math.Inf(1)"
+	case math.IsInf(v, -1):
+		return "math.Inf(-1)"
+	}
+	return formatFloat(v)
+}
+
+// Push adds a new value to the sample. It is the same as calling PushWeighted
+// with a weight of 1. See also [*Stats.SetWinsorize].
 func (s *Stats) Push(v float64) {
-	if s.n < s.maxN || s.maxN < 1 {
-		s.n++
+	s.PushWeighted(v, 1)
+}
+
+// PushSlice adds all the values in `vs` to the sample, in order. The result is
+// exactly the same as calling Push with each of them.
+func (s *Stats) PushSlice(vs []float64) {
+	for _, v := range vs {
+		s.PushWeighted(v, 1)
+	}
+}
+
+// PushWeighted adds a new value to the sample that counts as `weight` values,
+// using the weighted version of the Welford algorithm. N is incremented by
+// `weight`, and capped by MaxN the same as with Push, in which case `weight`
+// is also capped to MaxN. Values with a non-positive weight are ignored.
+func (s *Stats) PushWeighted(v, weight float64) {
+	if !(weight > 0) {
+		return
+	}
+	if s.actualN == 0 || v < s.min {
+		s.min = v
+	}
+	if s.actualN == 0 || v > s.max {
+		s.max = v
+	}
+	if s.winsorK > 0 && s.actualN > 1 {
+		sdThresh := s.winsorK * s.StdDev()
+		v = min(max(v, s.newM-sdThresh), s.newM+sdThresh)
 	}
-	if s.actualN++; s.actualN > 1 {
-		s.newM = math.FMA(s.oldM, s.n-1, v) / s.n
-		s.newS = math.FMA(math.Abs(v-s.oldM), math.Abs(v-s.newM), s.oldS)
+	if s.decay > 0 {
+		s.pushDecay(v, weight)
+		return
+	}
+	if s.maxN >= 1 && s.n > s.maxN {
+		// only possible with smoothMaxN, halve the excess
+		s.n = s.maxN + math.Floor((s.n-s.maxN)/2)
+	}
+	if s.maxN < 1 {
+		s.n += weight
+	} else if s.n < s.maxN {
+		s.n = min(s.n+weight, s.maxN)
+	}
+	weight = min(weight, s.n)
+	first := s.actualN == 0
+	if s.actualN += weight; !first {
+		s.newM = math.FMA(s.oldM, s.n-weight, weight*v) / s.n
+		s.newS = math.FMA(weight*math.Abs(v-s.oldM), math.Abs(v-s.newM),
+			s.oldS)
 		s.oldM = s.newM
 		s.oldS = s.newS
 
@@ -27,9 +143,70 @@ func (s *Stats) Push(v float64) {
 	}
 }
 
+// pushDecay updates the exponentially weighted Mean and Variance, as described
+// by Tony Finch in "Incremental calculation of weighted mean and variance". The
+// Variance is stored multiplied by actualN, so that it's read the same as in
+// the windowed mode.
+func (s *Stats) pushDecay(v, weight float64) {
+	prevN := s.actualN
+	s.n += weight
+	s.actualN += weight
+	if prevN == 0 {
+		s.oldM, s.newM = v, v
+		return
+	}
+	alpha := 1 - math.Pow(1-s.decay, weight)
+	diff := v - s.newM
+	incr := alpha * diff
+	variance := (1 - alpha) * (s.newS/prevN + diff*incr)
+	s.newM += incr
+	s.newS = variance * s.actualN
+	s.oldM, s.oldS = s.newM, s.newS
+}
+
+// Forget scales down N by `factor`, in the range (0, 1), keeping the Mean and
+// the Variance, so that the values pushed afterwards have a greater weight
+// relative to the previous ones. The total count of values is scaled as well,
+// but never below 2, so that the Variance remains defined. Values of `factor`
+// outside that range are ignored.
+func (s *Stats) Forget(factor float64) {
+	if !(factor > 0 && factor < 1) || s.actualN <= 2 {
+		return
+	}
+	factor = max(factor, 2/s.actualN)
+	s.n = max(s.n*factor, min(s.n, 1))
+	s.actualN *= factor
+	s.oldS *= factor
+	s.newS *= factor
+}
+
+// Clone returns a copy of `s`. Stats only holds values, so the copy is
+// independent of the original, and pushing to either doesn't affect the other.
+// This allows, for example, running what-if simulations on a snapshot of the
+// statistics of an AdaptivePool.
+func (s Stats) Clone() Stats { return s }
+
 // Reset clears all the data.
 func (s *Stats) Reset() { *s = Stats{} }
 
+// resetData clears the pushed data, but keeps the configuration.
+func (s *Stats) resetData() {
+	c := *s
+	*s = Stats{}
+	s.setConfig(&c)
+}
+
+// setConfig replaces the configuration of `s`, like MaxN and the decay, with
+// the one of `c`, keeping the data of `s`. As with SetMaxN, N is capped to the
+// new MaxN unless smoothing is enabled.
+func (s *Stats) setConfig(c *Stats) {
+	s.maxN, s.winsorK, s.smoothMaxN, s.decay = c.maxN, c.winsorK,
+		c.smoothMaxN, c.decay
+	if s.smoothMaxN == 0 && s.maxN >= 1 && s.n > s.maxN {
+		s.n = s.maxN
+	}
+}
+
 // N returns the number of pushed values.
 func (s *Stats) N() float64 { return s.n }
 
@@ -39,9 +216,11 @@ func (s *Stats) MaxN() float64 { return math.Round(s.maxN) }
 // SetMaxN will prevent the value of N (the number of observations) from being
 // incremented beyond `maxN`. This is useful to keep a bias towards latest
 // values, improving the adaptability to seasonal changes in data distribution.
-// Using a value less than one disables this behaviour. If the current value of
-// N is already higher, then it will be set to `maxN` immediately. A value too
-// low may cause instability, while a value too high may reduce adaptability.
+// Using a value less than one disables this behaviour. Using a value of one or
+// more disables the decay set with [*Stats.SetDecay]. If the current value of
+// N is already higher, then it will be set to `maxN` immediately, unless
+// smoothing was enabled with [*Stats.SetSmoothMaxN]. A value too low may cause
+// instability, while a value too high may reduce adaptability.
 //
 // NOTE: A recommended starting value is 500, if your application can tolerate
 // it, and probably no less than 100 otherwise. This recommendation could change
@@ -51,21 +230,340 @@ func (s *Stats) SetMaxN(maxN float64) {
 		maxN = 0
 	} else {
 		maxN = math.Round(maxN)
+		s.decay = 0
 	}
 	s.maxN = maxN
-	if s.maxN >= 1 && s.n > s.maxN {
+	if s.smoothMaxN == 0 && s.maxN >= 1 && s.n > s.maxN {
 		s.n = s.maxN
 	}
 }
 
+// SetSmoothMaxN sets whether lowering MaxN below the current value of N should
+// gradually decrease N over the following calls to Push, instead of
+// immediately. When enabled, each Push halves the difference between N and
+// MaxN, which avoids abrupt jumps when MaxN is frequently lowered, e.g. by an
+// auto-tuner. Disabling it sets N to MaxN immediately if needed.
+func (s *Stats) SetSmoothMaxN(smooth bool) {
+	s.smoothMaxN = 0
+	if smooth {
+		s.smoothMaxN = 1
+	}
+	s.SetMaxN(s.maxN)
+}
+
+// SetDecay makes Push compute an Exponentially Weighted Moving Average and
+// Variance, where `alpha`, in the range (0, 1), is the weight of each new value
+// relative to the previous Mean. Unlike with MaxN, the bias towards the latest
+// values applies smoothly from the beginning, with a memory comparable to a
+// MaxN of `2/alpha - 1`. The weight of values pushed with PushWeighted is
+// applied as if they had been pushed that many times. Decay and MaxN are
+// mutually exclusive, so this sets MaxN to zero, and a later call to SetMaxN
+// with a value of one or more disables the decay. N keeps counting all the
+// pushed values. Using a value outside that range disables this behaviour,
+// which is the default.
+func (s *Stats) SetDecay(alpha float64) {
+	if !(alpha > 0 && alpha < 1) {
+		s.decay = 0
+		return
+	}
+	s.decay = alpha
+	s.maxN = 0
+}
+
+// Decay returns the value set with [*Stats.SetDecay], or zero if disabled.
+func (s *Stats) Decay() float64 { return s.decay }
+
+// SetWinsorize makes Push clamp each value to the inclusive range `Mean ± k *
+// StdDev` before adding it to the sample, so that outliers are pulled in rather
+// than fully incorporated. This limits the damage of occasional extreme values
+// while still allowing to adapt to changes. Clamping is skipped while StdDev is
+// not defined. Using a value less than or equal to zero disables this
+// behaviour.
+func (s *Stats) SetWinsorize(k float64) {
+	s.winsorK = max(k, 0)
+}
+
 // Mean returns the Arithmetic Mean of the pushed values.
 func (s *Stats) Mean() float64 { return s.newM }
 
+// Min returns the minimum pushed value, before any winsorizing. It is the
+// all-time minimum since the last Reset, even if N is capped by MaxN, since
+// the minimum of a window can't be maintained without storing the values. It
+// returns NaN if it is unknown, e.g. if no values were pushed.
+func (s *Stats) Min() float64 {
+	if s.actualN == 0 || s.min > s.max {
+		return math.NaN()
+	}
+	return s.min
+}
+
+// Max returns the maximum pushed value, with the same semantics as Min.
+func (s *Stats) Max() float64 {
+	if s.actualN == 0 || s.min > s.max {
+		return math.NaN()
+	}
+	return s.max
+}
+
+// Sum returns the sum of the pushed values, computed as `Mean * N`. If N was
+// capped by MaxN, then this is not the total of all the pushed values, but a
+// windowed notion of it, i.e. the sum of the last MaxN values as weighted by
+// the Mean, since older values are progressively forgotten.
+func (s *Stats) Sum() float64 { return s.newM * s.n }
+
 // StdDev returns the (Population) Standard Deviation of the pushed values. If
 // less than 2 values were pushed, then NaN is returned.
 func (s *Stats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Variance returns the (Population) Variance of the pushed values, which is the
+// square of StdDev, without the loss of precision of squaring it. If less than
+// 2 values were pushed, then NaN is returned.
+func (s *Stats) Variance() float64 {
 	if s.actualN > 1 {
-		return math.Sqrt(s.newS / s.actualN)
+		return s.newS / s.actualN
 	}
 	return math.NaN()
 }
+
+// SampleStdDev returns the (Sample) Standard Deviation of the pushed values,
+// using Bessel's correction, which is an estimator of the Standard Deviation of
+// the population the values were sampled from. The policy of an AdaptivePool
+// uses StdDev instead. If less than 2 values were pushed, then NaN is returned.
+func (s *Stats) SampleStdDev() float64 {
+	if s.actualN > 1 {
+		return math.Sqrt(s.newS / (s.actualN - 1))
+	}
+	return math.NaN()
+}
+
+// CoefficientOfVariation returns StdDev divided by Mean, which is a measure of
+// the dispersion of the values relative to their size. A low value means that
+// sizing items based on the Mean is reliable. If less than 2 values were
+// pushed, then NaN is returned. If the Mean is zero, then +Inf is returned, or
+// NaN if the StdDev is also zero.
+func (s *Stats) CoefficientOfVariation() float64 {
+	return s.StdDev() / s.Mean()
+}
+
+// StdError returns the Standard Error of the Mean, which is StdDev divided by
+// the square root of the number of values pushed. It shrinks as more values
+// are pushed, so it's a cheap measure of how much the Mean can be trusted,
+// e.g. to decide when to stop creating items with a conservative size. If less
+// than 2 values were pushed, then NaN is returned.
+func (s *Stats) StdError() float64 {
+	return s.StdDev() / math.Sqrt(s.actualN)
+}
+
+// ZScore returns the number of Standard Deviations that `v` is away from the
+// Mean, with a negative sign if it's less than the Mean. It returns NaN if the
+// Standard Deviation is undefined or zero.
+func (s *Stats) ZScore(v float64) float64 {
+	sd := s.StdDev()
+	if sd == 0 {
+		return math.NaN()
+	}
+	return (v - s.Mean()) / sd
+}
+
+// AcceptProbability returns the probability that a value falls within the
+// inclusive range `Mean ± threshold * StdDev`, assuming a Normal Distribution.
+// This is the expected ratio of items accepted by [NormalSlice] and similar
+// providers for the given Threshold. It returns NaN if StdDev is undefined.
+func (s *Stats) AcceptProbability(threshold float64) float64 {
+	if math.IsNaN(s.StdDev()) {
+		return math.NaN()
+	}
+	return math.Erf(threshold / math.Sqrt2)
+}
+
+// SizeForNoGrowRate returns the size such that a fraction `p` of the values
+// are expected to be less than or equal to it, assuming a Normal Distribution.
+// That is, `Mean + z * StdDev`, where `z` is the `p`-quantile of the Standard
+// Normal Distribution. A provider can use it in Create to target the rate at
+// which created items will not need to grow, instead of using a Threshold. It
+// returns NaN if StdDev is undefined or if `p` is not in the range [0, 1].
+func (s *Stats) SizeForNoGrowRate(p float64) float64 {
+	sd := s.StdDev()
+	if math.IsNaN(sd) || !(p >= 0 && p <= 1) {
+		return math.NaN()
+	}
+	return s.Mean() + math.Sqrt2*math.Erfinv(2*p-1)*sd
+}
+
+// Merge combines the values pushed to `other` into `s`, as if they had all been
+// pushed to `s`, using the parallel algorithm by Chan, Golub and LeVeque. The
+// MaxN of `s` is kept, and N is capped to it if needed. The MaxN of `other` is
+// only relevant in that it may have already capped its N, which is then used
+// to weight its Mean.
+func (s *Stats) Merge(other Stats) {
+	if other.actualN == 0 {
+		return
+	}
+	if s.actualN == 0 {
+		s.n, s.actualN = other.n, other.actualN
+		s.oldM, s.newM = other.oldM, other.newM
+		s.oldS, s.newS = other.oldS, other.newS
+		s.min, s.max = other.min, other.max
+		s.SetMaxN(s.maxN)
+		return
+	}
+	s.min, s.max = min(s.min, other.min), max(s.max, other.max)
+
+	n := s.n + other.n
+	actualN := s.actualN + other.actualN
+	delta := other.newM - s.newM
+
+	s.newM = math.FMA(delta, other.n/n, s.newM)
+	s.newS = math.FMA(delta*delta, s.actualN*other.actualN/actualN,
+		s.newS+other.newS)
+	s.oldM, s.oldS = s.newM, s.newS
+	s.n, s.actualN = n, actualN
+	s.SetMaxN(s.maxN)
+}
+
+// MergeStats returns the result of combining `a` and `b` as with
+// [*Stats.Merge], but symmetrically: the resulting MaxN is the sum of both, or
+// zero if either of them is not limited, the same as with [AggregateStats], so
+// that merging never caps N. Either side may have fewer than 2 values, in which
+// case its StdDev being NaN doesn't affect the result.
+func MergeStats(a, b Stats) Stats {
+	if a.maxN < 1 || b.maxN < 1 {
+		a.SetMaxN(0)
+	} else {
+		a.SetMaxN(a.maxN + b.maxN)
+	}
+	a.Merge(b)
+	return a
+}
+
+// statsBinaryFields has the number of fields encoded by each version of the
+// binary format, which are a prefix of the fields returned by binaryFields.
+var statsBinaryFields = [...]int{1: 8, 2: 9, 3: 11, 4: 12}
+
+const statsBinaryVersion = byte(len(statsBinaryFields) - 1)
+
+// MarshalBinary is part of the implementation of the
+// [encoding.BinaryMarshaler] interface. All the internal state is encoded, so
+// that a decoded Stats continues accumulating values as the original would.
+func (s *Stats) MarshalBinary() ([]byte, error) {
+	fields := s.binaryFields()
+	b := make([]byte, 0, 1+8*len(fields))
+	b = append(b, statsBinaryVersion)
+	for _, f := range fields {
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(*f))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary is part of the implementation of the
+// [encoding.BinaryUnmarshaler] interface.
+func (s *Stats) UnmarshalBinary(data []byte) error {
+	var tmp Stats
+	if len(data) == 0 || data[0] < 1 || data[0] > statsBinaryVersion {
+		return errors.New("Stats.UnmarshalBinary: unsupported version")
+	}
+	fields := tmp.binaryFields()[:statsBinaryFields[data[0]]]
+	if len(data) != 1+8*len(fields) {
+		return errors.New("Stats.UnmarshalBinary: invalid length")
+	}
+	tmp.min, tmp.max = math.Inf(1), math.Inf(-1) // unknown in old versions
+	for i, f := range fields {
+		*f = math.Float64frombits(binary.BigEndian.Uint64(data[1+8*i:]))
+	}
+	*s = tmp
+	return nil
+}
+
+// statsJSONNames are the names of the fields returned by binaryFields in the
+// JSON encoding.
+var statsJSONNames = [...]string{
+	"n", "actualN", "maxN",
+	"oldM", "newM",
+	"oldS", "newS",
+	"winsorK",
+	"smoothMaxN",
+	"min", "max",
+	"decay",
+}
+
+// MarshalJSON is part of the implementation of the [encoding/json.Marshaler]
+// interface. All the internal state is encoded, the same as with
+// MarshalBinary. Values that are not valid JSON numbers, like NaN, are encoded
+// as the strings "NaN", "+Inf" and "-Inf".
+func (s Stats) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, f := range s.binaryFields() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, statsJSONNames[i])
+		b = append(b, ':')
+		b = appendJSONFloat(b, *f)
+	}
+	return append(b, '}'), nil
+}
+
+func appendJSONFloat(b []byte, v float64) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(b, `"NaN"`...)
+	case math.IsInf(v, 1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(v, -1):
+		return append(b, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(b, v, 'g', -1, 64)
+}
+
+// UnmarshalJSON is part of the implementation of the
+// [encoding/json.Unmarshaler] interface. Missing fields are left with their
+// zero value, except for Min and Max which are unknown.
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var m map[string]jsonFloat64
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("Stats.UnmarshalJSON: %w", err)
+	}
+	tmp := Stats{min: math.Inf(1), max: math.Inf(-1)}
+	for i, f := range tmp.binaryFields() {
+		if v, ok := m[statsJSONNames[i]]; ok {
+			*f = float64(v)
+		}
+	}
+	*s = tmp
+	return nil
+}
+
+// jsonFloat64 decodes JSON numbers, and the strings written by appendJSONFloat.
+type jsonFloat64 float64
+
+func (f *jsonFloat64) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return err
+	}
+	*f = jsonFloat64(v)
+	return nil
+}
+
+func (s *Stats) binaryFields() []*float64 {
+	return []*float64{
+		&s.n, &s.actualN, &s.maxN,
+		&s.oldM, &s.newM,
+		&s.oldS, &s.newS,
+		&s.winsorK,
+		&s.smoothMaxN,
+		&s.min, &s.max,
+		&s.decay,
+	}
+}
@@ -1,9 +1,12 @@
 package adaptivepool
 
 import (
+	"bytes"
 	"cmp"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"go/parser"
 	"io"
 	"math"
 	"math/rand/v2"
@@ -319,3 +322,855 @@ func (ms muSigmas) statsMu() muSigmaStats {
 func (ms muSigmas) statsStdDev() muSigmaStats {
 	return ms.stats(func(ms muSigma) float64 { return ms.sigma })
 }
+
+func TestNewStatsSeed(t *testing.T) {
+	t.Parallel()
+
+	st := NewStatsSeed(0, 42, 3)
+	zero(t, st.N(), "N for n < 1")
+	zero(t, st.Mean(), "Mean for n < 1")
+
+	st = NewStatsSeed(1, 42, 3)
+	equal(t, 1, st.N(), "N for n = 1")
+	equal(t, 42, st.Mean(), "Mean for n = 1")
+	equal(t, true, math.IsNaN(st.StdDev()), "StdDev for n = 1")
+
+	st = NewStatsSeed(10, 42, 3)
+	equal(t, 10, st.N(), "N")
+	equal(t, 42, st.Mean(), "Mean")
+	equal(t, 3, st.StdDev(), "StdDev")
+
+	// should keep accumulating as if the values were actually pushed
+	var pushed Stats
+	for _, v := range []float64{39, 45, 39, 45} {
+		pushed.Push(v)
+	}
+	seeded := NewStatsSeed(pushed.N(), pushed.Mean(), pushed.StdDev())
+	pushed.Push(50)
+	seeded.Push(50)
+	equal(t, pushed.N(), seeded.N(), "N after Push")
+	equal(t, pushed.Mean(), seeded.Mean(), "Mean after Push")
+	equal(t, pushed.StdDev(), seeded.StdDev(), "StdDev after Push")
+}
+
+func TestStatsGoSeedExpr(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		stats    Stats
+		expected string
+	}{
+		{
+			stats:    Stats{},
+			expected: "adaptivepool.NewStatsSeed(0, 0, math.NaN())",
+		},
+		{
+			stats:    NewStatsSeed(1, 10, 0),
+			expected: "adaptivepool.NewStatsSeed(1, 10, math.NaN())",
+		},
+		{
+			stats:    NewStatsSeed(500, 1024.5, 128.25),
+			expected: "adaptivepool.NewStatsSeed(500, 1024.5, 128.25)",
+		},
+		{
+			stats:    NewStatsSeed(3, 1e21, 0.1),
+			expected: "adaptivepool.NewStatsSeed(3, 1e+21, 0.1)",
+		},
+	}
+
+	for i, tc := range testCases {
+		got := tc.stats.GoSeedExpr()
+		equal(t, tc.expected, got, "[#%d] unexpected expression", i)
+		_, err := parser.ParseExpr(got)
+		zero(t, err, "[#%d] parse expression %q", i, got)
+	}
+}
+
+func TestStatsString(t *testing.T) {
+	t.Parallel()
+
+	var st Stats
+	equal(t, "Stats{N:0 Mean:0 StdDev:NaN MaxN:0}", st.String(), "zero value")
+
+	st.SetMaxN(500)
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		st.Push(v)
+	}
+	want := "Stats{N:8 Mean:5 StdDev:2 MaxN:500}"
+	equal(t, want, st.String(), "populated")
+	equal(t, want, fmt.Sprint(&st), "should be used by fmt")
+}
+
+func TestStatsZScore(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.ZScore(1)), "ZScore in zero value")
+
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.ZScore(1)), "ZScore with n < 2")
+
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.ZScore(1)), "ZScore with zero std dev")
+
+	st.Push(20)
+	st.Push(20)
+	// mean=15 ; stdDev=5
+	equal(t, 0, st.ZScore(15), "ZScore of the mean")
+	equal(t, 1, st.ZScore(20), "ZScore one std dev above")
+	equal(t, -2, st.ZScore(5), "ZScore two std devs below")
+	equal(t, 0.5, st.ZScore(17.5), "ZScore half std dev above")
+}
+
+func TestStatsMerge(t *testing.T) {
+	t.Parallel()
+
+	push := func(st *Stats, vs ...float64) {
+		for _, v := range vs {
+			st.Push(v)
+		}
+	}
+	assertStatsEqual := func(want, got Stats, msg string) {
+		t.Helper()
+		equal(t, want.N(), got.N(), "%s: N", msg)
+		equal(t, want.MaxN(), got.MaxN(), "%s: MaxN", msg)
+		equal(t, roundOneDecimal(want.Mean()), roundOneDecimal(got.Mean()),
+			"%s: Mean", msg)
+		wantSD, gotSD := want.StdDev(), got.StdDev()
+		equal(t, math.IsNaN(wantSD), math.IsNaN(gotSD), "%s: NaN StdDev", msg)
+		if !math.IsNaN(wantSD) {
+			equal(t, roundOneDecimal(wantSD), roundOneDecimal(gotSD),
+				"%s: StdDev", msg)
+		}
+	}
+
+	var a, b, all Stats
+	a.Merge(b)
+	assertStatsEqual(all, a, "both empty")
+
+	push(&b, 10)
+	push(&all, 10)
+	a.Merge(b)
+	assertStatsEqual(all, a, "empty receiver")
+
+	b.Reset()
+	push(&b, 20)
+	push(&all, 20)
+	a.Merge(b)
+	assertStatsEqual(all, a, "single values on each side")
+
+	a.Merge(Stats{})
+	assertStatsEqual(all, a, "empty argument")
+
+	b.Reset()
+	push(&b, 30, 30, 50, 50)
+	push(&all, 30, 30, 50, 50)
+	a.Merge(b)
+	assertStatsEqual(all, a, "several values")
+
+	a.SetMaxN(3)
+	b.SetMaxN(100)
+	a.Merge(b)
+	equal(t, 3, a.N(), "N should be capped to the receiver's MaxN")
+	equal(t, 3, a.MaxN(), "the receiver's MaxN should be kept")
+}
+
+func TestMergeStats(t *testing.T) {
+	t.Parallel()
+
+	values := allTestDataInputValues(t)
+	assertClose := func(want, got Stats, msg string) {
+		t.Helper()
+		equal(t, want.N(), got.N(), "%s: N", msg)
+		for _, f := range []struct {
+			name      string
+			want, got float64
+		}{
+			{"Mean", want.Mean(), got.Mean()},
+			{"StdDev", want.StdDev(), got.StdDev()},
+		} {
+			if math.IsNaN(f.want) || math.IsNaN(f.got) {
+				equal(t, math.IsNaN(f.want), math.IsNaN(f.got), "%s: NaN %s",
+					msg, f.name)
+				continue
+			}
+			if relErr := math.Abs(f.got-f.want) / f.want; relErr > 1e-9 {
+				t.Fatalf("%s: %s: want %v, got %v, relative error: %v", msg,
+					f.name, f.want, f.got, relErr)
+			}
+		}
+	}
+
+	for _, split := range []int{0, 1, 2, len(values) / 2, len(values) - 1} {
+		var a, b, all Stats
+		for _, v := range values[:split] {
+			a.Push(v)
+		}
+		for _, v := range values[split:] {
+			b.Push(v)
+		}
+		for _, v := range values {
+			all.Push(v)
+		}
+		msg := fmt.Sprintf("split at %d", split)
+		assertClose(all, MergeStats(a, b), msg)
+		assertClose(all, MergeStats(b, a), msg+" (swapped)")
+	}
+
+	var a, b Stats
+	a.Push(1)
+	b.Push(2)
+	a.SetMaxN(10)
+	b.SetMaxN(20)
+	ab := MergeStats(a, b)
+	equal(t, 30, ab.MaxN(), "MaxN should be the sum, as with AggregateStats")
+	b.SetMaxN(0)
+	ab = MergeStats(a, b)
+	zero(t, ab.MaxN(), "MaxN should be unlimited if either is")
+	equal(t, 10, a.MaxN(), "arguments should not change")
+
+	// the result is exactly the same as with Merge using the summed MaxN
+	a, b = Stats{}, Stats{}
+	a.SetMaxN(50)
+	b.SetMaxN(50)
+	for i, v := range values[:200] {
+		if i%3 == 0 {
+			a.Push(v)
+		} else {
+			b.Push(v)
+		}
+	}
+	merged := a
+	merged.SetMaxN(100)
+	merged.Merge(b)
+	equal(t, merged, MergeStats(a, b), "MergeStats should match Merge")
+	equal(t, 100, merged.N(), "N should not be capped by merging")
+}
+
+func TestStatsWinsorize(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	st.SetWinsorize(1)
+	st.Push(10)
+	st.Push(1000)
+	equal(t, 505, st.Mean(), "should not clamp while StdDev is not defined")
+
+	values := []float64{100, 110, 90, 105, 95, 100, 102, 98}
+	outliers := []float64{10_000, 1, 20_000}
+	var raw, winsorized, clean Stats
+	winsorized.SetWinsorize(2)
+	for _, v := range values {
+		raw.Push(v)
+		winsorized.Push(v)
+		clean.Push(v)
+	}
+	for _, v := range outliers {
+		raw.Push(v)
+		winsorized.Push(v)
+	}
+	for _, v := range values {
+		raw.Push(v)
+		winsorized.Push(v)
+		clean.Push(v)
+	}
+
+	rawMeanErr := math.Abs(raw.Mean() - clean.Mean())
+	winMeanErr := math.Abs(winsorized.Mean() - clean.Mean())
+	if winMeanErr >= rawMeanErr {
+		t.Errorf("winsorized mean should be less affected by outliers; "+
+			"winsorized error: %v; raw error: %v", winMeanErr, rawMeanErr)
+	}
+
+	rawSDErr := math.Abs(raw.StdDev() - clean.StdDev())
+	winSDErr := math.Abs(winsorized.StdDev() - clean.StdDev())
+	if winSDErr >= rawSDErr {
+		t.Errorf("winsorized std dev should be less affected by outliers; "+
+			"winsorized error: %v; raw error: %v", winSDErr, rawSDErr)
+	}
+
+	st.Reset()
+	st.SetWinsorize(-1)
+	st.Push(10)
+	st.Push(10)
+	st.Push(1000)
+	equal(t, 340, st.Mean(), "should not clamp when disabled")
+}
+
+func TestStatsBinary(t *testing.T) {
+	t.Parallel()
+
+	values := allTestDataInputValues(t)
+	half := len(values) / 2
+
+	var uninterrupted, original Stats
+	uninterrupted.SetMaxN(500)
+	uninterrupted.SetWinsorize(3)
+	original.SetMaxN(500)
+	original.SetWinsorize(3)
+	for _, v := range values[:half] {
+		uninterrupted.Push(v)
+		original.Push(v)
+	}
+
+	b, err := original.MarshalBinary()
+	zero(t, err, "MarshalBinary")
+	var restored Stats
+	zero(t, restored.UnmarshalBinary(b), "UnmarshalBinary")
+	equal(t, original, restored, "restored Stats")
+
+	for _, v := range values[half:] {
+		uninterrupted.Push(v)
+		restored.Push(v)
+	}
+	equal(t, uninterrupted, restored, "should continue accumulating the same")
+
+	err = restored.UnmarshalBinary(nil)
+	equal(t, true, err != nil, "should fail with empty data")
+	err = restored.UnmarshalBinary([]byte{statsBinaryVersion + 1})
+	equal(t, true, err != nil, "should fail with unsupported version")
+	err = restored.UnmarshalBinary(b[:len(b)-1])
+	equal(t, true, err != nil, "should fail with invalid length")
+	equal(t, uninterrupted, restored, "should not change on error")
+
+	// previous versions should be supported
+	b[0] = 1
+	zero(t, restored.UnmarshalBinary(b[:1+8*statsBinaryFields[1]]),
+		"UnmarshalBinary version 1")
+	equal(t, true, math.IsNaN(restored.Min()) && math.IsNaN(restored.Max()),
+		"Min and Max should be unknown in version 1")
+	restored.min, restored.max = original.min, original.max
+	equal(t, original, restored, "restored Stats from version 1")
+}
+
+func TestStatsJSON(t *testing.T) {
+	t.Parallel()
+
+	values := allTestDataInputValues(t)
+	half := len(values) / 2
+
+	var uninterrupted, original Stats
+	uninterrupted.SetMaxN(500)
+	uninterrupted.SetWinsorize(3)
+	original.SetMaxN(500)
+	original.SetWinsorize(3)
+	for _, v := range values[:half] {
+		uninterrupted.Push(v)
+		original.Push(v)
+	}
+
+	b, err := json.Marshal(original)
+	zero(t, err, "json.Marshal")
+	var restored Stats
+	zero(t, json.Unmarshal(b, &restored), "json.Unmarshal")
+	equal(t, original, restored, "restored Stats")
+
+	for _, v := range values[half:] {
+		uninterrupted.Push(v)
+		restored.Push(v)
+	}
+	equal(t, uninterrupted, restored, "should continue accumulating the same")
+
+	// values that are not valid JSON numbers
+	seed := NewStatsSeed(1, math.NaN(), 0)
+	b, err = json.Marshal(seed)
+	zero(t, err, "json.Marshal with NaN and Inf")
+	equal(t, true, bytes.Contains(b, []byte(`"newM":"NaN"`)) &&
+		bytes.Contains(b, []byte(`"min":"+Inf"`)) &&
+		bytes.Contains(b, []byte(`"max":"-Inf"`)),
+		"unexpected encoding of NaN and Inf: %s", b)
+	restored = Stats{}
+	zero(t, json.Unmarshal(b, &restored), "json.Unmarshal with NaN and Inf")
+	equal(t, true, math.IsNaN(restored.Mean()), "Mean should be NaN")
+	seed.oldM, seed.newM = 0, 0 // NaN is not equal to itself
+	restored.oldM, restored.newM = 0, 0
+	equal(t, seed, restored, "restored Stats with NaN and Inf")
+
+	zero(t, json.Unmarshal([]byte(`{"n":2,"maxN":null}`), &restored),
+		"json.Unmarshal with missing fields")
+	equal(t, 2, restored.N(), "N")
+	equal(t, true, math.IsNaN(restored.Min()), "Min should be unknown")
+
+	err = json.Unmarshal([]byte(`{"n":"many"}`), &restored)
+	equal(t, true, err != nil, "should fail with invalid number")
+	err = json.Unmarshal([]byte(`[]`), &restored)
+	equal(t, true, err != nil, "should fail with invalid object")
+	equal(t, 2, restored.N(), "should not change on error")
+}
+
+func TestStatsMinMax(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.Min()), "Min of zero value")
+	equal(t, true, math.IsNaN(st.Max()), "Max of zero value")
+
+	st.SetMaxN(500)
+	st.SetWinsorize(1)
+	values := allTestDataInputValues(t)
+	wantMin, wantMax := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		st.Push(v)
+		wantMin, wantMax = min(wantMin, v), max(wantMax, v)
+		equal(t, wantMin, st.Min(), "[#%d] Min", i)
+		equal(t, wantMax, st.Max(), "[#%d] Max", i)
+	}
+
+	half := len(values) / 2
+	var a, b Stats
+	for _, v := range values[:half] {
+		a.Push(v)
+	}
+	for _, v := range values[half:] {
+		b.Push(v)
+	}
+	a.Merge(b)
+	equal(t, wantMin, a.Min(), "Min after Merge")
+	equal(t, wantMax, a.Max(), "Max after Merge")
+
+	seed := NewStatsSeed(100, 10, 1)
+	equal(t, true, math.IsNaN(seed.Min()), "Min of seed")
+	seed.Push(12)
+	equal(t, 12, seed.Min(), "Min of seed after Push")
+	equal(t, 12, seed.Max(), "Max of seed after Push")
+
+	st.Reset()
+	equal(t, true, math.IsNaN(st.Min()), "Min after Reset")
+	equal(t, true, math.IsNaN(st.Max()), "Max after Reset")
+}
+
+func TestStatsSmoothMaxN(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	st.SetSmoothMaxN(true)
+	for i := 0; i < 100; i++ {
+		st.Push(1)
+	}
+	st.SetMaxN(10)
+	equal(t, 100, st.N(), "N should not be capped immediately")
+
+	prev := st.N()
+	for i := 0; st.N() > st.MaxN(); i++ {
+		st.Push(1)
+		if n := st.N(); n >= prev || n < st.MaxN() {
+			t.Fatalf("[#%d] N should decrease towards MaxN; previous: %v; "+
+				"got: %v; MaxN: %v", i, prev, n, st.MaxN())
+		}
+		prev = st.N()
+	}
+	equal(t, 10, st.N(), "N should reach MaxN")
+	st.Push(1)
+	equal(t, 10, st.N(), "N should stay at MaxN")
+
+	st.SetMaxN(0)
+	for i := 0; i < 90; i++ {
+		st.Push(1)
+	}
+	st.SetMaxN(50)
+	equal(t, 100, st.N(), "N should not be capped immediately")
+	st.SetSmoothMaxN(false)
+	equal(t, 50, st.N(), "N should be capped when disabling smoothing")
+}
+
+func TestStatsAcceptProbability(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.AcceptProbability(1)), "zero value")
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.AcceptProbability(1)), "n < 2")
+	st.Push(20)
+
+	testCases := []struct {
+		threshold, expected float64
+	}{
+		{0, 0},
+		{1, 0.6827},
+		{2, 0.9545},
+		{2.5, 0.9876},
+		{3, 0.9973},
+	}
+	for i, tc := range testCases {
+		got := math.Round(st.AcceptProbability(tc.threshold)*1e4) / 1e4
+		equal(t, tc.expected, got, "[#%d] threshold=%v", i, tc.threshold)
+	}
+}
+
+func TestStatsSizeForNoGrowRate(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.SizeForNoGrowRate(0.9)), "zero value")
+	st.Push(100)
+	equal(t, true, math.IsNaN(st.SizeForNoGrowRate(0.9)), "n < 2")
+	*st = NewStatsSeed(100, 1000, 100)
+
+	testCases := []struct {
+		p, expected float64
+	}{
+		{0.5, 1000},
+		{0.9, 1128.155},
+		{0.99, 1232.635},
+		{0.1, 871.845},
+	}
+	for i, tc := range testCases {
+		got := math.Round(st.SizeForNoGrowRate(tc.p)*1e3) / 1e3
+		equal(t, tc.expected, got, "[#%d] p=%v", i, tc.p)
+	}
+	equal(t, math.Inf(1), st.SizeForNoGrowRate(1), "p=1")
+	equal(t, true, math.IsNaN(st.SizeForNoGrowRate(1.5)), "p > 1")
+	equal(t, true, math.IsNaN(st.SizeForNoGrowRate(-1)), "p < 0")
+	equal(t, true, math.IsNaN(st.SizeForNoGrowRate(math.NaN())), "NaN p")
+}
+
+func TestStatsVariance(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.Variance()), "zero value")
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.Variance()), "n < 2")
+
+	st.SetMaxN(500)
+	for i, v := range allTestDataInputValues(t) {
+		st.Push(v)
+		sd, variance := st.StdDev(), st.Variance()
+		if relErr := math.Abs(sd*sd-variance) / variance; relErr > 1e-12 {
+			t.Fatalf("[#%d] StdDev squared differs from Variance; StdDev: "+
+				"%v, Variance: %v, relative error: %v", i, sd, variance,
+				relErr)
+		}
+	}
+}
+
+func TestStatsDecay(t *testing.T) {
+	t.Parallel()
+	const maxN = 100
+
+	var windowed, decayed Stats
+	windowed.SetMaxN(maxN)
+	decayed.SetDecay(2.0 / (maxN + 1)) // comparable memory
+	equal(t, 2.0/(maxN+1), decayed.Decay(), "Decay")
+	zero(t, decayed.MaxN(), "MaxN should be disabled")
+
+	// alternate between mean ± 10 so that the StdDev is 10
+	push := func(mean float64, i int) {
+		v := mean - 10
+		if i%2 == 1 {
+			v = mean + 10
+		}
+		windowed.Push(v)
+		decayed.Push(v)
+	}
+	for i := 0; i < 1000; i++ {
+		push(100, i)
+	}
+	if math.Abs(decayed.Mean()-100) > 1 ||
+		math.Abs(decayed.StdDev()-10) > 0.2 {
+		t.Fatalf("unexpected stats before the step change; Mean: %v, "+
+			"StdDev: %v", decayed.Mean(), decayed.StdDev())
+	}
+	equal(t, 1000, decayed.N(), "N should count all the values")
+
+	// count the values needed to cover 90% of a step change
+	windowedSteps, decayedSteps := -1, -1
+	for i := 0; windowedSteps < 0 || decayedSteps < 0; i++ {
+		push(200, i)
+		if windowedSteps < 0 && windowed.Mean() >= 190 {
+			windowedSteps = i
+		}
+		if decayedSteps < 0 && decayed.Mean() >= 190 {
+			decayedSteps = i
+		}
+	}
+	if decayedSteps >= windowedSteps {
+		t.Fatalf("EWMA should track the step change faster; pushes needed "+
+			"with decay: %d, with MaxN: %d", decayedSteps, windowedSteps)
+	}
+
+	b, err := decayed.MarshalBinary()
+	zero(t, err, "MarshalBinary")
+	var restored Stats
+	zero(t, restored.UnmarshalBinary(b), "UnmarshalBinary")
+	equal(t, decayed, restored, "restored Stats")
+
+	decayed.Reset()
+	decayed.SetDecay(0.5)
+	decayed.resetData()
+	equal(t, 0.5, decayed.Decay(), "resetData should keep the decay")
+	decayed.SetMaxN(maxN)
+	zero(t, decayed.Decay(), "SetMaxN should disable the decay")
+	decayed.SetDecay(1)
+	zero(t, decayed.Decay(), "out of range")
+}
+
+func TestStatsCoefficientOfVariation(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.CoefficientOfVariation()), "zero value")
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.CoefficientOfVariation()), "n < 2")
+	st.Push(30)
+	equal(t, 0.5, st.CoefficientOfVariation(), "two values")
+
+	st.Reset()
+	st.Push(-1)
+	st.Push(1)
+	equal(t, math.Inf(1), st.CoefficientOfVariation(), "zero Mean")
+	st.Reset()
+	st.Push(0)
+	st.Push(0)
+	equal(t, true, math.IsNaN(st.CoefficientOfVariation()),
+		"zero Mean and StdDev")
+
+	st.Reset()
+	values := allTestDataInputValues(t)
+	var sum float64
+	for _, v := range values {
+		st.Push(v)
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sumSq float64
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+	want := math.Sqrt(sumSq/float64(len(values))) / mean
+	got := st.CoefficientOfVariation()
+	if relErr := math.Abs(got-want) / want; relErr > 1e-9 {
+		t.Fatalf("CoefficientOfVariation differs from batch computation; "+
+			"want: %v, got: %v, relative error: %v", want, got, relErr)
+	}
+}
+
+func TestStatsStdError(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.StdError()), "zero value")
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.StdError()), "n < 2")
+	st.Push(30)
+	equal(t, 10/math.Sqrt(2), st.StdError(), "two values")
+
+	st.Reset()
+	values := allTestDataInputValues(t)
+	var sum float64
+	var prev float64
+	for i, v := range values {
+		st.Push(v)
+		sum += v
+		if i == 100 {
+			prev = st.StdError()
+		}
+	}
+	n := float64(len(values))
+	mean := sum / n
+	var sumSq float64
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+	want := math.Sqrt(sumSq/n) / math.Sqrt(n)
+	got := st.StdError()
+	if relErr := math.Abs(got-want) / want; relErr > 1e-9 {
+		t.Fatalf("StdError differs from batch computation; want: %v, got: %v,"+
+			" relative error: %v", want, got, relErr)
+	}
+	equal(t, true, got < prev, "StdError should decrease as N grows; "+
+		"N=101: %v, N=%v: %v", prev, n, got)
+}
+
+func TestStatsSampleStdDev(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	equal(t, true, math.IsNaN(st.SampleStdDev()), "zero value")
+	st.Push(10)
+	equal(t, true, math.IsNaN(st.SampleStdDev()), "n < 2")
+	st.Push(20)
+	equal(t, math.Sqrt(50), st.SampleStdDev(), "two values")
+
+	st.Reset()
+	values := allTestDataInputValues(t)
+	var sum float64
+	for _, v := range values {
+		st.Push(v)
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sumSq float64
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+	want := math.Sqrt(sumSq / float64(len(values)-1))
+	if relErr := math.Abs(st.SampleStdDev()-want) / want; relErr > 1e-9 {
+		t.Fatalf("SampleStdDev differs from batch computation; want: %v, "+
+			"got: %v, relative error: %v", want, st.SampleStdDev(), relErr)
+	}
+	equal(t, true, st.SampleStdDev() > st.StdDev(),
+		"should be greater than StdDev")
+}
+
+func TestStatsSum(t *testing.T) {
+	t.Parallel()
+
+	st := new(Stats)
+	zero(t, st.Sum(), "zero value")
+
+	var sum float64
+	for _, v := range allTestDataInputValues(t) {
+		st.Push(v)
+		sum += v
+	}
+	if relErr := math.Abs(st.Sum()-sum) / sum; relErr > 1e-9 {
+		t.Fatalf("Sum differs from total; want: %v, got: %v, relative "+
+			"error: %v", sum, st.Sum(), relErr)
+	}
+
+	st.SetMaxN(10)
+	equal(t, 10*st.Mean(), st.Sum(), "Sum should be windowed when capped")
+}
+
+func TestStatsPushSlice(t *testing.T) {
+	t.Parallel()
+
+	values := allTestDataInputValues(t)
+	setups := map[string]func(*Stats){
+		"default":   func(*Stats) {},
+		"MaxN":      func(s *Stats) { s.SetMaxN(50) },
+		"smooth":    func(s *Stats) { s.SetMaxN(50); s.SetSmoothMaxN(true) },
+		"winsorize": func(s *Stats) { s.SetWinsorize(2) },
+		"decay":     func(s *Stats) { s.SetDecay(0.1) },
+	}
+	for name, setup := range setups {
+		var want, got Stats
+		setup(&want)
+		setup(&got)
+		for _, v := range values {
+			want.Push(v)
+		}
+		got.PushSlice(values[:len(values)/2])
+		got.PushSlice(nil)
+		got.PushSlice(values[len(values)/2:])
+		equal(t, want, got, "%s: PushSlice should match Push", name)
+	}
+}
+
+func TestStatsPushWeighted(t *testing.T) {
+	t.Parallel()
+
+	values := allTestDataInputValues(t)
+	weight := func(i int) float64 {
+		return 0.5 + float64(i%5)/2
+	}
+
+	st := new(Stats)
+	var sumW, sumWV float64
+	for i, v := range values {
+		st.PushWeighted(v, weight(i))
+		sumW += weight(i)
+		sumWV += weight(i) * v
+	}
+	mean := sumWV / sumW
+	var sumWSq float64
+	for i, v := range values {
+		sumWSq += weight(i) * (v - mean) * (v - mean)
+	}
+	stdDev := math.Sqrt(sumWSq / sumW)
+
+	assertRelErr := func(name string, want, got float64) {
+		t.Helper()
+		if relErr := math.Abs(got-want) / want; relErr > 1e-9 {
+			t.Fatalf("%s: want: %v, got: %v, relative error: %v", name,
+				want, got, relErr)
+		}
+	}
+	assertRelErr("N", sumW, st.N())
+	assertRelErr("Mean", mean, st.Mean())
+	assertRelErr("StdDev", stdDev, st.StdDev())
+
+	before := *st
+	st.PushWeighted(1e9, 0)
+	st.PushWeighted(1e9, -1)
+	st.PushWeighted(1e9, math.NaN())
+	equal(t, before, *st, "non-positive weights should be ignored")
+
+	// unweighted Push should be the same as weight 1
+	var pushed, weighted Stats
+	pushed.SetMaxN(500)
+	weighted.SetMaxN(500)
+	for _, v := range values {
+		pushed.Push(v)
+		weighted.PushWeighted(v, 1)
+	}
+	equal(t, pushed, weighted, "Push should be PushWeighted with weight 1")
+
+	// MaxN caps the accumulated weight
+	st.Reset()
+	st.SetMaxN(10)
+	st.PushWeighted(10, 4)
+	st.PushWeighted(20, 4)
+	equal(t, 8, st.N(), "N below MaxN")
+	equal(t, 15, st.Mean(), "Mean below MaxN")
+	st.PushWeighted(30, 4)
+	equal(t, 10, st.N(), "N should be capped")
+	st.PushWeighted(40, 20)
+	equal(t, 10, st.N(), "N should stay capped")
+	equal(t, 40, st.Mean(), "weight should be capped to MaxN")
+}
+
+func TestStatsForget(t *testing.T) {
+	t.Parallel()
+
+	var s Stats
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.Push(v)
+	}
+	s.Forget(0.5)
+	equal(t, 4, s.N(), "N should be halved")
+	equal(t, 5, s.Mean(), "Mean should be kept")
+	equal(t, 2, s.StdDev(), "StdDev should be kept")
+
+	s.Forget(1)
+	s.Forget(0)
+	s.Forget(math.NaN())
+	equal(t, 4, s.N(), "invalid factors should be ignored")
+
+	s.Forget(0.01)
+	equal(t, 2, s.N(), "N should not go below 2")
+	equal(t, 2, s.StdDev(), "StdDev should still be defined")
+
+	// new values have a greater weight than before
+	s.Push(11)
+	equal(t, 7, s.Mean(), "Mean after pushing")
+
+	s = Stats{}
+	s.Push(1)
+	s.Forget(0.5)
+	equal(t, 1, s.N(), "should not change with one value")
+}
+
+func TestStatsClone(t *testing.T) {
+	t.Parallel()
+
+	var original Stats
+	original.SetMaxN(500)
+	for _, v := range []float64{10, 20, 30} {
+		original.Push(v)
+	}
+	n, mean, sd := original.N(), original.Mean(), original.StdDev()
+
+	clone := original.Clone()
+	equal(t, original, clone, "clone should be equal")
+	for _, v := range []float64{1000, 2000} {
+		clone.Push(v)
+	}
+	clone.SetMaxN(1)
+	equal(t, n, original.N(), "N of the original")
+	equal(t, mean, original.Mean(), "Mean of the original")
+	equal(t, sd, original.StdDev(), "StdDev of the original")
+	equal(t, 500, original.MaxN(), "MaxN of the original")
+	equal(t, 1, clone.N(), "N of the clone")
+}
@@ -0,0 +1,81 @@
+package adaptivepool
+
+import "math"
+
+// PoolStatus is a simple health signal of an [AdaptivePool], telling whether
+// its statistics are warmed up and stable.
+type PoolStatus int
+
+// Possible statuses of an AdaptivePool.
+const (
+	// StatusCold means that too few items were put to have meaningful stats.
+	StatusCold PoolStatus = iota
+	// StatusWarming means that N has not reached MaxN yet.
+	StatusWarming
+	// StatusStable means that the stats are warmed up and they are not
+	// significantly moving.
+	StatusStable
+	// StatusShifting means that the stats are warmed up but they are moving,
+	// e.g. because of a change in the distribution of sizes.
+	StatusShifting
+)
+
+var poolStatusNames = [...]string{"cold", "warming", "stable", "shifting"}
+
+// String is part of the implementation of the fmt.Stringer interface.
+func (s PoolStatus) String() string {
+	if s < 0 || int(s) >= len(poolStatusNames) {
+		return "PoolStatus(" + formatDecimal(float64(s)) + ")"
+	}
+	return poolStatusNames[s]
+}
+
+// The movement of the stats is checked every statusWindow pushes, and it is
+// considered significant if the Mean moved more than statusMaxMove times the
+// previous StdDev, or if the StdDev changed by more than statusMaxMove times
+// its previous value. With a MaxN of 500, the Mean of a stable distribution
+// is expected to move about 0.01 StdDevs per window.
+const (
+	statusMinN    = 32
+	statusWindow  = 32
+	statusMaxMove = 0.1
+)
+
+// statusTracker keeps track of the movement of the stats of an AdaptivePool.
+type statusTracker struct {
+	pushes         int
+	mean, stdDev   float64 // at the start of the current window
+	moving, primed bool
+}
+
+// push must be called after each push to `s`.
+func (t *statusTracker) push(s StatsProvider) {
+	if t.pushes++; t.pushes < statusWindow {
+		return
+	}
+	mean, stdDev := s.Mean(), s.StdDev()
+	if t.primed {
+		t.moving = math.Abs(mean-t.mean) > statusMaxMove*t.stdDev ||
+			math.Abs(stdDev-t.stdDev) > statusMaxMove*t.stdDev
+	}
+	t.pushes, t.mean, t.stdDev, t.primed = 0, mean, stdDev, true
+}
+
+// Status returns whether the pool is cold, when fewer than a minimum number of
+// items were put, warming, while N is less than MaxN, and otherwise whether
+// the stats are stable or shifting, based on their recent movement. If MaxN is
+// not set, then the pool is never reported as warming.
+func (p *AdaptivePool[T]) Status() PoolStatus {
+	p.statsMu.RLock()
+	defer p.statsMu.RUnlock()
+	n, maxN := p.stats.N(), p.stats.MaxN()
+	switch {
+	case n < statusMinN:
+		return StatusCold
+	case maxN >= 1 && n < maxN:
+		return StatusWarming
+	case p.status.moving:
+		return StatusShifting
+	}
+	return StatusStable
+}
@@ -0,0 +1,43 @@
+package adaptivepool
+
+import "testing"
+
+func TestAdaptivePoolStatus(t *testing.T) {
+	t.Parallel()
+
+	ap := New[int](intProvider{}, 100)
+	ap.setPool(nopPool{})
+	equal(t, StatusCold, ap.Status(), "empty pool")
+
+	var i int
+	putUntil := func(want PoolStatus, base, maxPuts int) {
+		t.Helper()
+		for j := 0; ap.Status() != want; j++ {
+			if j == maxPuts {
+				t.Fatalf("status %v not reached after %d puts; got: %v",
+					want, maxPuts, ap.Status())
+			}
+			ap.Put(base + (i*37)%21 - 10)
+			i++
+		}
+	}
+
+	putUntil(StatusWarming, 100, statusMinN)
+	equal(t, statusMinN, i, "should be warming after minimum puts")
+	putUntil(StatusStable, 100, 100+statusWindow)
+	st := ap.Stats()
+	equal(t, 100, st.N(), "should be stable at MaxN")
+	putUntil(StatusStable, 100, 10*statusWindow) // should remain stable
+	equal(t, StatusStable, ap.Status(), "stable distribution")
+
+	// regime change
+	putUntil(StatusShifting, 1000, 2*statusWindow)
+	putUntil(StatusStable, 1000, 20*statusWindow)
+	st = ap.Stats()
+	if m := st.Mean(); m < 900 || m > 1010 {
+		t.Fatalf("should be stable after mostly adapting; Mean: %v", m)
+	}
+
+	equal(t, "warming", StatusWarming.String(), "String")
+	equal(t, "PoolStatus(42)", PoolStatus(42).String(), "unknown String")
+}